@@ -0,0 +1,284 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package channel
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"v.io/v23/verror"
+)
+
+// teeBuffer is the number of items by which a source returned by teeSources
+// may fall behind the others.
+const teeBuffer = 16
+
+// source produces the items of a channel, one at a time.  Once exhausted, it
+// returns an error for which isEOF is true.
+type source func() (interface{}, error)
+
+func isEOF(err error) bool {
+	return err == io.EOF || verror.ErrorID(err) == verror.ErrEndOfFile.ID
+}
+
+// sticky returns a source that, once the provided source returns an error,
+// keeps returning that error without invoking the provided source again.
+func sticky(src source) source {
+	var mu sync.Mutex
+	var err error
+	return func() (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		var item interface{}
+		item, err = src()
+		return item, err
+	}
+}
+
+// mergeSources returns a source that produces the items of all provided
+// sources, in the order they become available.  The merged source is
+// exhausted once all provided sources are exhausted; the first other error
+// returned by any of the sources ends the merged source.
+//
+// Closing done stops the goroutines reading from the provided sources; the
+// items they hold at that time are passed to release.
+func mergeSources(done <-chan struct{}, release func(interface{}), srcs ...source) source {
+	out := make(chan prefetchResult)
+	var wg sync.WaitGroup
+	for _, src := range srcs {
+		wg.Add(1)
+		go func(src source) {
+			defer wg.Done()
+			for {
+				item, err := src()
+				if isEOF(err) {
+					return
+				}
+				select {
+				case out <- prefetchResult{item, err}:
+				case <-done:
+					if err == nil {
+						release(item)
+					}
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(src)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return sticky(func() (interface{}, error) {
+		select {
+		case r, ok := <-out:
+			if !ok {
+				return nil, io.EOF
+			}
+			return r.item, r.err
+		case <-done:
+			return nil, io.EOF
+		}
+	})
+}
+
+// filterSource returns a source that produces only the items of the provided
+// source that satisfy the provided predicate.  Items that don't satisfy it
+// are passed to release.
+func filterSource(src source, pred func(interface{}) (bool, error), release func(interface{})) source {
+	return sticky(func() (interface{}, error) {
+		for {
+			item, err := src()
+			if err != nil {
+				return nil, err
+			}
+			ok, err := pred(item)
+			if err != nil {
+				release(item)
+				return nil, err
+			}
+			if ok {
+				return item, nil
+			}
+			release(item)
+		}
+	})
+}
+
+// takeSource returns a source that produces at most n items of the provided
+// source.
+func takeSource(src source, n int) source {
+	taken := 0
+	return sticky(func() (interface{}, error) {
+		if taken >= n {
+			return nil, io.EOF
+		}
+		taken++
+		return src()
+	})
+}
+
+// timeoutSource returns a source that produces the items of the provided
+// source, but fails with verror.ErrTimeout if any item takes longer than
+// timeout to arrive.  The item that eventually arrives after a timeout is
+// passed to release.
+func timeoutSource(src source, timeout time.Duration, release func(interface{})) source {
+	var once sync.Once
+	items := make(chan prefetchResult)
+	done := make(chan struct{})
+	pump := func() {
+		for {
+			item, err := src()
+			select {
+			case items <- prefetchResult{item, err}:
+			case <-done:
+				if err == nil {
+					release(item)
+				}
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return sticky(func() (interface{}, error) {
+		once.Do(func() { go pump() })
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case r := <-items:
+			return r.item, r.err
+		case <-timer.C:
+			close(done)
+			return nil, verror.New(verror.ErrTimeout, nil, "no item received within", timeout)
+		}
+	})
+}
+
+// teeSources returns n sources, each of which produces all items of the
+// provided source, as well as its errors.  The items are produced in
+// lockstep: reading from the provided source continues only once every
+// returned source has taken the previous item (or buffered it, for up to
+// buffer items).  The copy function returns the item to be handed to the
+// i-th returned source.
+//
+// Closing the i-th detach channel stops handing items to the i-th source; the
+// items it would have been handed are passed to release instead.  Closing
+// done stops the goroutine reading from the provided source; the items not
+// yet taken at that time are passed to release.
+func teeSources(src source, buffer int, copy func(item interface{}, i int) interface{}, done <-chan struct{}, release func(interface{}), detach ...<-chan struct{}) []source {
+	n := len(detach)
+	outs := make([]chan prefetchResult, n)
+	for i := range outs {
+		outs[i] = make(chan prefetchResult, buffer)
+	}
+	releaseAll := func(out chan prefetchResult) {
+		for {
+			select {
+			case r, ok := <-out:
+				if !ok {
+					return
+				}
+				if r.err == nil {
+					release(r.item)
+				}
+			default:
+				return
+			}
+		}
+	}
+	var once sync.Once
+	pump := func() {
+		stopped := false
+		defer func() {
+			for i, out := range outs {
+				if stopped || isClosed(detach[i]) {
+					releaseAll(out)
+				}
+				close(out)
+			}
+			if !stopped {
+				// Release whatever remains buffered once the tee is stopped.
+				go func() {
+					<-done
+					for _, out := range outs {
+						releaseAll(out)
+					}
+				}()
+			}
+		}()
+		for {
+			item, err := src()
+			for i, out := range outs {
+				if isClosed(detach[i]) {
+					if err == nil {
+						release(copy(item, i))
+					}
+					releaseAll(out)
+					continue
+				}
+				r := prefetchResult{err: err}
+				if err == nil {
+					r.item = copy(item, i)
+				}
+				select {
+				case out <- r:
+				case <-detach[i]:
+					if err == nil {
+						release(r.item)
+					}
+				case <-done:
+					if err == nil {
+						release(r.item)
+						for j := i + 1; j < n; j++ {
+							release(copy(item, j))
+						}
+					}
+					stopped = true
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	srcs := make([]source, n)
+	for i := range srcs {
+		out, detached := outs[i], detach[i]
+		srcs[i] = sticky(func() (interface{}, error) {
+			once.Do(func() { go pump() })
+			select {
+			case r, ok := <-out:
+				if !ok {
+					return nil, io.EOF
+				}
+				return r.item, r.err
+			case <-detached:
+				return nil, io.EOF
+			case <-done:
+				return nil, io.EOF
+			}
+		})
+	}
+	return srcs
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package channel
+
+import (
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"v.io/v23/vdl"
+	"v.io/v23/verror"
+)
+
+// sliceSource returns a source that produces the provided items and then
+// io.EOF.
+func sliceSource(items ...interface{}) source {
+	ch := make(chan interface{}, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return func() (interface{}, error) {
+		item, ok := <-ch
+		if !ok {
+			return nil, io.EOF
+		}
+		return item, nil
+	}
+}
+
+// drainSource returns all items produced by src until its first error.
+func drainSource(t *testing.T, src source) []interface{} {
+	var items []interface{}
+	for {
+		item, err := src()
+		if isEOF(err) {
+			return items
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		items = append(items, item)
+	}
+}
+
+func noRelease(interface{}) {}
+
+func TestMergeSources(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	src := mergeSources(done, noRelease, sliceSource(1, 2, 3), sliceSource(), sliceSource(4, 5))
+	var got []int
+	for _, item := range drainSource(t, src) {
+		got = append(got, item.(int))
+	}
+	sort.Ints(got)
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := src(); err != io.EOF {
+		t.Errorf("got error %v after the end of the merged sources, want io.EOF", err)
+	}
+}
+
+func TestMergeSourcesDone(t *testing.T) {
+	c := &counter{}
+	done := make(chan struct{})
+	src := mergeSources(done, c.release, c.recv)
+	if _, err := src(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(done)
+	// The item received while the merged source was being stopped is released.
+	waitFor(t, "the pending item to be released", func() bool {
+		_, released := c.counts()
+		return released == 1
+	})
+	if _, err := src(); err != io.EOF {
+		t.Errorf("got error %v, want io.EOF", err)
+	}
+}
+
+func TestFilterAndTakeSources(t *testing.T) {
+	c := &counter{}
+	even := func(item interface{}) (bool, error) { return item.(int)%2 == 0, nil }
+	src := takeSource(filterSource(c.recv, even, c.release), 3)
+	if got, want := drainSource(t, src), []interface{}{2, 4, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The odd items were released, and nothing was received past the limit.
+	if produced, released := c.counts(); produced != 6 || released != 3 {
+		t.Errorf("got %d items produced and %d released, want 6 and 3", produced, released)
+	}
+}
+
+func TestTimeoutSource(t *testing.T) {
+	items := make(chan interface{}, 1)
+	items <- "a"
+	c := &counter{}
+	src := timeoutSource(func() (interface{}, error) { return <-items, nil }, 50*time.Millisecond, c.release)
+	if item, err := src(); err != nil || item != "a" {
+		t.Fatalf("got (%v, %v), want (a, nil)", item, err)
+	}
+	if _, err := src(); verror.ErrorID(err) != verror.ErrTimeout.ID {
+		t.Fatalf("got error %v, want %v", err, verror.ErrTimeout.ID)
+	}
+	// The item that arrives late is released, and the timeout is sticky.
+	items <- "b"
+	waitFor(t, "the late item to be released", func() bool {
+		_, released := c.counts()
+		return released == 1
+	})
+	if _, err := src(); verror.ErrorID(err) != verror.ErrTimeout.ID {
+		t.Errorf("got error %v, want %v", err, verror.ErrTimeout.ID)
+	}
+}
+
+func TestTeeSources(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	copies := func(item interface{}, i int) interface{} { return []interface{}{item, i} }
+	srcs := teeSources(sliceSource("a", "b"), 1, copies, done, noRelease, make(chan struct{}), make(chan struct{}))
+	for _, want := range []string{"a", "b"} {
+		for i, src := range srcs {
+			item, err := src()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := item.([]interface{}); got[0] != want || got[1] != i {
+				t.Errorf("tee %d: got %v, want [%s %d]", i, got, want, i)
+			}
+		}
+	}
+	for i, src := range srcs {
+		if _, err := src(); err != io.EOF {
+			t.Errorf("tee %d: got error %v, want io.EOF", i, err)
+		}
+	}
+}
+
+func TestTeeSourcesDone(t *testing.T) {
+	c := &counter{}
+	done := make(chan struct{})
+	srcs := teeSources(c.recv, 0, func(item interface{}, _ int) interface{} { return item }, done, c.release, make(chan struct{}), make(chan struct{}))
+	if _, err := srcs[0](); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The second tee never takes the first item; stopping the tee releases it.
+	close(done)
+	waitFor(t, "the untaken item to be released", func() bool {
+		_, released := c.counts()
+		return released == 1
+	})
+}
+
+func TestTeeSourcesDetach(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	detached := make(chan struct{})
+	srcs := teeSources(sliceSource("a", "b", "c"), 0, func(item interface{}, _ int) interface{} { return item }, done, noRelease, make(chan struct{}), detached)
+	if item, err := srcs[0](); err != nil || item != "a" {
+		t.Fatalf("got (%v, %v), want (a, nil)", item, err)
+	}
+	// Once the second tee is detached, the first one no longer waits for it.
+	close(detached)
+	if got, want := drainSource(t, srcs[0]), []interface{}{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := srcs[1](); err != io.EOF {
+		t.Errorf("got error %v from the detached tee, want io.EOF", err)
+	}
+}
+
+type testItem struct {
+	Name  string
+	Count int
+	Inner *testInner
+}
+
+type testInner struct {
+	Label string
+}
+
+func TestVDLPredicate(t *testing.T) {
+	item := testItem{"server-1", 5, &testInner{"x"}}
+	tests := []struct {
+		path, op string
+		operand  interface{}
+		want     bool
+	}{
+		{"Name", "==", "server-1", true},
+		{"Name", "!=", "server-1", false},
+		{"Name", "prefix", "server", true},
+		{"Name", "prefix", "client", false},
+		{"Name", "<", "server-2", true},
+		{"Count", ">=", 5, true},
+		{"Count", ">", 5, false},
+		{"Count", "<=", 7.5, true},
+		{"Inner.Label", "==", "x", true},
+		{"", "==", item, true},
+	}
+	for _, test := range tests {
+		p, err := newVDLPredicate(test.path, test.op, vdl.ValueOf(test.operand))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := p.match(item); err != nil || got != test.want {
+			t.Errorf("%s %s %v: got (%v, %v), want (%v, nil)", test.path, test.op, test.operand, got, err, test.want)
+		}
+	}
+	// A nil optional field doesn't satisfy ordering predicates.
+	operand := vdl.ValueOf("x")
+	p, _ := newVDLPredicate("Inner.Label", ">", operand)
+	if got, err := p.match(testItem{}); err != nil || got {
+		t.Errorf("got (%v, %v) for a nil field, want (false, nil)", got, err)
+	}
+}
+
+func TestVDLPredicateErrors(t *testing.T) {
+	operand := vdl.ValueOf("x")
+	if _, err := newVDLPredicate("Name", "~", operand); err == nil {
+		t.Errorf("unknown operator accepted")
+	}
+	for _, test := range []struct{ path, op string }{
+		{"Missing", "=="},
+		{"Name.Inner", "=="},
+		{"Count", "prefix"},
+		{"Count", "<"},
+	} {
+		p, err := newVDLPredicate(test.path, test.op, operand)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.match(testItem{Name: "a"}); err == nil {
+			t.Errorf("%s %s: expected an error", test.path, test.op)
+		}
+	}
+}
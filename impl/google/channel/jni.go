@@ -7,9 +7,11 @@
 package channel
 
 import (
+	"fmt"
 	"unsafe"
 
 	jutil "v.io/x/jni/util"
+	jcontext "v.io/x/jni/v23/context"
 )
 
 // #include "jni.h"
@@ -40,16 +42,156 @@ func Init(env jutil.Env) error {
 }
 
 //export Java_io_v_impl_google_channel_InputChannelImpl_nativeRecv
-func Java_io_v_impl_google_channel_InputChannelImpl_nativeRecv(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRecvRef C.jlong, jCallbackObj C.jobject) {
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeRecv(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRef C.jlong, jCallbackObj C.jobject) {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
-	recv := *(*func() (jutil.Object, error))(jutil.GoRefValue(jutil.Ref(goRecvRef)))
+	ch := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
 	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
-	jutil.DoAsyncCall(env, jCallback, recv)
+	jutil.DoAsyncCall(env, jCallback, ch.next)
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeSetPrefetch
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeSetPrefetch(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRef C.jlong, jSize C.jint) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ch := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
+	if err := ch.setPrefetch(int(jSize)); err != nil {
+		jutil.JThrowV(env, err)
+	}
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeCancel
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeCancel(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRef C.jlong) {
+	(*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef))).close(true)
 }
 
 //export Java_io_v_impl_google_channel_InputChannelImpl_nativeFinalize
-func Java_io_v_impl_google_channel_InputChannelImpl_nativeFinalize(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRecvRef C.jlong) {
-	jutil.GoDecRef(jutil.Ref(goRecvRef))
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeFinalize(jenv *C.JNIEnv, jInputChannelImpl C.jobject, goRef C.jlong) {
+	// Release any prefetched items; they can no longer be received.
+	(*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef))).close(false)
+	jutil.GoDecRef(jutil.Ref(goRef))
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeMerge
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeMerge(jenv *C.JNIEnv, jInputChannelClass C.jclass, jContext C.jobject, jGoRefs C.jlongArray) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	var inputs []*inputChannel
+	for _, goRef := range jutil.GoLongArray(env, jutil.Object(uintptr(unsafe.Pointer(jGoRefs)))) {
+		inputs = append(inputs, (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef))))
+	}
+	jChannel, err := combine(env, ctx, inputs, func(done <-chan struct{}, srcs []source) source {
+		return mergeSources(done, releaseItem, srcs...)
+	})
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jChannel))
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeFilter
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeFilter(jenv *C.JNIEnv, jInputChannelClass C.jclass, jContext C.jobject, goRef C.jlong, jPath C.jstring, jOp C.jstring, jVomOperand C.jbyteArray) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	input := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
+	if !input.values {
+		jutil.JThrowV(env, fmt.Errorf("only channels of VDL values can be filtered"))
+		return nil
+	}
+	path := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jPath))))
+	op := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jOp))))
+	operand, err := jutil.VomDecodeToValue(jutil.GoByteArray(env, jutil.Object(uintptr(unsafe.Pointer(jVomOperand)))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	p, err := newVDLPredicate(path, op, operand)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jChannel, err := combine(env, ctx, []*inputChannel{input}, func(_ <-chan struct{}, srcs []source) source {
+		return filterSource(srcs[0], func(item interface{}) (bool, error) {
+			return p.match(item.(goItem).value)
+		}, releaseItem)
+	})
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jChannel))
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeTake
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeTake(jenv *C.JNIEnv, jInputChannelClass C.jclass, jContext C.jobject, goRef C.jlong, jN C.jlong) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	input := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
+	jChannel, err := combine(env, ctx, []*inputChannel{input}, func(_ <-chan struct{}, srcs []source) source {
+		return takeSource(srcs[0], int(jN))
+	})
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jChannel))
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeTimeout
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeTimeout(jenv *C.JNIEnv, jInputChannelClass C.jclass, jContext C.jobject, goRef C.jlong, jTimeout C.jobject) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	timeout, err := jutil.GoDuration(env, jutil.Object(uintptr(unsafe.Pointer(jTimeout))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	input := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
+	jChannel, err := combine(env, ctx, []*inputChannel{input}, func(_ <-chan struct{}, srcs []source) source {
+		return timeoutSource(srcs[0], timeout, releaseItem)
+	})
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jChannel))
+}
+
+//export Java_io_v_impl_google_channel_InputChannelImpl_nativeTee
+func Java_io_v_impl_google_channel_InputChannelImpl_nativeTee(jenv *C.JNIEnv, jInputChannelClass C.jclass, jContext C.jobject, goRef C.jlong, jN C.jint) C.jobjectArray {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	input := (*inputChannel)(jutil.GoRefValue(jutil.Ref(goRef)))
+	jChannels, err := tee(env, ctx, input, int(jN))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jArr, err := jutil.JObjectArray(env, jChannels, jInputChannelImplClass)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobjectArray(unsafe.Pointer(jArr))
 }
 
 //export Java_io_v_impl_google_channel_OutputChannelImpl_nativeSend
@@ -0,0 +1,168 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package channel
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"v.io/v23/vdl"
+)
+
+// vdlPredicate compares a field of a VDL value against an operand.
+type vdlPredicate struct {
+	path    []string // field names leading to the compared field; empty for the value itself
+	op      string
+	operand *vdl.Value
+}
+
+// newVDLPredicate creates a predicate that compares the field of a value
+// selected by the dot-separated path with the provided operand, using one of
+// the operators "==", "!=", "<", "<=", ">", ">=" or "prefix".  Path elements
+// select struct fields and union fields; a value whose union holds a
+// different field than the one selected doesn't satisfy the predicate.
+// Any and optional values are dereferenced along the way.
+func newVDLPredicate(path, op string, operand *vdl.Value) (*vdlPredicate, error) {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "prefix":
+	default:
+		return nil, fmt.Errorf("unknown predicate operator %q", op)
+	}
+	if operand == nil {
+		return nil, fmt.Errorf("nil predicate operand")
+	}
+	p := &vdlPredicate{op: op, operand: deref(operand)}
+	if path != "" {
+		p.path = strings.Split(path, ".")
+	}
+	return p, nil
+}
+
+// deref strips any and optional wrappers from the provided value, returning
+// nil for nil values.
+func deref(v *vdl.Value) *vdl.Value {
+	for v != nil && (v.Kind() == vdl.Any || v.Kind() == vdl.Optional) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// match returns true iff the provided value satisfies the predicate.
+func (p *vdlPredicate) match(item interface{}) (bool, error) {
+	v, ok := item.(*vdl.Value)
+	if !ok {
+		var err error
+		if v, err = vdl.ValueFromReflect(reflect.ValueOf(item)); err != nil {
+			return false, err
+		}
+	}
+	for _, name := range p.path {
+		if v = deref(v); v == nil {
+			return false, nil
+		}
+		switch v.Kind() {
+		case vdl.Struct:
+			field := v.StructFieldByName(name)
+			if field == nil {
+				return false, fmt.Errorf("type %v has no field %q", v.Type(), name)
+			}
+			v = field
+		case vdl.Union:
+			index, field := v.UnionField()
+			if v.Type().Field(index).Name != name {
+				if _, i := v.Type().FieldByName(name); i < 0 {
+					return false, fmt.Errorf("type %v has no field %q", v.Type(), name)
+				}
+				return false, nil
+			}
+			v = field
+		default:
+			return false, fmt.Errorf("cannot select field %q of %v value", name, v.Kind())
+		}
+	}
+	if v = deref(v); v == nil {
+		// Only equality is defined for nil values.
+		switch p.op {
+		case "==", "!=":
+			return (p.op == "==") == (p.operand == nil), nil
+		}
+		return false, nil
+	}
+	switch p.op {
+	case "==":
+		return vdl.EqualValue(v, p.operand), nil
+	case "!=":
+		return !vdl.EqualValue(v, p.operand), nil
+	case "prefix":
+		s, ok := stringOf(v)
+		prefix, ok2 := stringOf(p.operand)
+		if !ok || !ok2 {
+			return false, fmt.Errorf("operator prefix applied to %v and %v values", v.Kind(), p.operand.Kind())
+		}
+		return strings.HasPrefix(s, prefix), nil
+	}
+	cmp, err := compare(v, p.operand)
+	if err != nil {
+		return false, err
+	}
+	switch p.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default: // ">="
+		return cmp >= 0, nil
+	}
+}
+
+func stringOf(v *vdl.Value) (string, bool) {
+	switch v.Kind() {
+	case vdl.String:
+		return v.RawString(), true
+	case vdl.Enum:
+		return v.EnumLabel(), true
+	}
+	return "", false
+}
+
+func numberOf(v *vdl.Value) (float64, bool) {
+	switch v.Kind() {
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		return float64(v.Uint()), true
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		return float64(v.Int()), true
+	case vdl.Float32, vdl.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// compare returns -1, 0 or 1 depending on whether a is less than, equal to,
+// or greater than b.  Only numbers and strings can be compared.
+func compare(a, b *vdl.Value) (int, error) {
+	if x, ok := numberOf(a); ok {
+		if y, ok := numberOf(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if x, ok := stringOf(a); ok {
+		if y, ok := stringOf(b); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %v and %v values", a.Kind(), b.Kind())
+}
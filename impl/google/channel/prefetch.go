@@ -0,0 +1,124 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package channel
+
+import (
+	"errors"
+	"sync"
+)
+
+var errCanceled = errors.New("channel canceled")
+
+// prefetcher receives items ahead of the consumer, in its own goroutine, and
+// buffers them.  When the buffer is full, the goroutine stops receiving until
+// the consumer catches up, so a slow consumer also slows down the producer.
+type prefetcher struct {
+	recv    func() (interface{}, error)
+	release func(interface{}) // releases items that will never be consumed
+	items   chan prefetchResult
+
+	startOnce  sync.Once
+	cancelOnce sync.Once
+	canceled   chan struct{}
+
+	mu  sync.Mutex
+	err error // terminal error, once consumed
+}
+
+type prefetchResult struct {
+	item interface{}
+	err  error
+}
+
+// newPrefetcher creates a prefetcher that buffers up to size items received
+// with the provided recv function.  Items that are received but never
+// consumed, because the prefetcher is canceled, are passed to release.
+func newPrefetcher(size int, recv func() (interface{}, error), release func(interface{})) *prefetcher {
+	if size < 1 {
+		size = 1
+	}
+	return &prefetcher{
+		recv:     recv,
+		release:  release,
+		items:    make(chan prefetchResult, size),
+		canceled: make(chan struct{}),
+	}
+}
+
+func (p *prefetcher) run() {
+	for {
+		item, err := p.recv()
+		select {
+		case p.items <- prefetchResult{item, err}:
+		case <-p.canceled:
+			if err == nil {
+				p.release(item)
+			}
+			return
+		}
+		select {
+		case <-p.canceled:
+			// The item may have been buffered after cancel() drained the
+			// buffer.
+			p.drain()
+			return
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// next returns the next item, blocking until it is available.  Once recv
+// returns an error, or the prefetcher is canceled, next keeps returning the
+// same error (errCanceled for cancellations).
+func (p *prefetcher) next() (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	select {
+	case <-p.canceled:
+		p.err = errCanceled
+		return nil, p.err
+	default:
+	}
+	p.startOnce.Do(func() { go p.run() })
+	select {
+	case r := <-p.items:
+		if r.err != nil {
+			p.err = r.err
+		}
+		return r.item, r.err
+	case <-p.canceled:
+		p.err = errCanceled
+		return nil, p.err
+	}
+}
+
+// cancel stops the prefetching goroutine (once its current receive
+// completes) and releases all buffered items.  It is safe to call cancel
+// multiple times.
+func (p *prefetcher) cancel() {
+	p.cancelOnce.Do(func() {
+		close(p.canceled)
+		p.drain()
+	})
+}
+
+func (p *prefetcher) drain() {
+	for {
+		select {
+		case r := <-p.items:
+			if r.err == nil {
+				p.release(r.item)
+			}
+		default:
+			return
+		}
+	}
+}
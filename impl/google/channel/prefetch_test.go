@@ -0,0 +1,111 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package channel
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// counter produces increasing integers and records the released ones.
+type counter struct {
+	mu       sync.Mutex
+	produced int
+	released []interface{}
+}
+
+func (c *counter) recv() (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.produced++
+	return c.produced, nil
+}
+
+func (c *counter) release(item interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.released = append(c.released, item)
+}
+
+func (c *counter) counts() (produced, released int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.produced, len(c.released)
+}
+
+// waitFor waits until cond is true, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrefetchBackpressure(t *testing.T) {
+	c := &counter{}
+	p := newPrefetcher(3, c.recv, c.release)
+	for want := 1; want <= 2; want++ {
+		if item, err := p.next(); err != nil || item != want {
+			t.Fatalf("got (%v, %v), want (%d, nil)", item, err, want)
+		}
+	}
+	// Three items are buffered and a fourth is blocked waiting for space.
+	waitFor(t, "the buffer to fill", func() bool {
+		produced, _ := c.counts()
+		return produced == 6
+	})
+	time.Sleep(10 * time.Millisecond)
+	if produced, _ := c.counts(); produced != 6 {
+		t.Errorf("producer received %d items with a full buffer, want 6", produced)
+	}
+	p.cancel()
+	// The buffered items and the blocked one are all released.
+	waitFor(t, "the items to be released", func() bool {
+		_, released := c.counts()
+		return released == 4
+	})
+	if _, err := p.next(); err != errCanceled {
+		t.Errorf("got error %v after cancel, want %v", err, errCanceled)
+	}
+}
+
+func TestPrefetchError(t *testing.T) {
+	items := []interface{}{"a", "b"}
+	p := newPrefetcher(5, func() (interface{}, error) {
+		if len(items) == 0 {
+			return nil, io.EOF
+		}
+		item := items[0]
+		items = items[1:]
+		return item, nil
+	}, func(interface{}) {})
+	for _, want := range []string{"a", "b"} {
+		if item, err := p.next(); err != nil || item != want {
+			t.Fatalf("got (%v, %v), want (%s, nil)", item, err, want)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.next(); err != io.EOF {
+			t.Errorf("got error %v, want io.EOF", err)
+		}
+	}
+}
+
+func TestPrefetchCancelBeforeStart(t *testing.T) {
+	c := &counter{}
+	p := newPrefetcher(1, c.recv, c.release)
+	p.cancel()
+	if _, err := p.next(); err != errCanceled {
+		t.Errorf("got error %v, want %v", err, errCanceled)
+	}
+	if produced, _ := c.counts(); produced != 0 {
+		t.Errorf("canceled prefetcher received %d items", produced)
+	}
+}
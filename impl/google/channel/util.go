@@ -7,7 +7,12 @@
 package channel
 
 import (
+	"fmt"
+	"io"
+	"sync"
+
 	"v.io/v23/context"
+	"v.io/v23/verror"
 
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
@@ -22,12 +27,41 @@ import "C"
 //
 // The recv function must return verror.ErrEndOfFile when there are no more elements
 // to receive.
+//
+// By default, the channel invokes recv once per item requested by Java; Java may turn on
+// prefetching before receiving the first item.  Canceling the channel from Java invokes
+// ctxCancel (if non-nil), which must cause recv to return; the producer is stopped that way
+// only on explicit cancellation, not when the channel is garbage-collected.
 func JavaInputChannel(env jutil.Env, ctx *context.T, ctxCancel func(), recv func() (jutil.Object, error)) (jutil.Object, error) {
-	jContext, err := jcontext.JavaContext(env, ctx, ctxCancel)
+	return newJavaInputChannel(env, &inputChannel{ctx: ctx, cancel: ctxCancel, src: func() (interface{}, error) {
+		return recv()
+	}})
+}
+
+// JavaValueInputChannel is like JavaInputChannel, except that recv returns Go values, which
+// are converted to Java only when they are handed to Java.  Unlike those of other channels,
+// the items of such channels can be filtered by the channel combinators.
+//
+// The convert function is invoked with a valid Java environment and may return a local
+// reference.
+func JavaValueInputChannel(env jutil.Env, ctx *context.T, ctxCancel func(), recv func() (interface{}, error), convert func(jutil.Env, interface{}) (jutil.Object, error)) (jutil.Object, error) {
+	return newJavaInputChannel(env, &inputChannel{ctx: ctx, cancel: ctxCancel, values: true, src: func() (interface{}, error) {
+		value, err := recv()
+		if err != nil {
+			return nil, err
+		}
+		return goItem{value, convert}, nil
+	}})
+}
+
+// newJavaInputChannel creates a new Java InputChannel object for the provided
+// Go state.
+func newJavaInputChannel(env jutil.Env, ch *inputChannel) (jutil.Object, error) {
+	jContext, err := jcontext.JavaContext(env, ch.ctx, ch.cancel)
 	if err != nil {
 		return jutil.NullObject, err
 	}
-	ref := jutil.GoNewRef(&recv) // Un-refed when jInputChannel is finalized.
+	ref := jutil.GoNewRef(ch) // Un-refed when jInputChannel is finalized.
 	jInputChannel, err := jutil.NewObject(env, jInputChannelImplClass, []jutil.Sign{contextSign, jutil.LongSign}, jContext, int64(ref))
 	if err != nil {
 		jutil.GoDecRef(ref)
@@ -36,6 +70,262 @@ func JavaInputChannel(env jutil.Env, ctx *context.T, ctxCancel func(), recv func
 	return jInputChannel, nil
 }
 
+// goItem is a channel item that is converted to Java only when it is handed
+// to Java.
+type goItem struct {
+	value   interface{}
+	convert func(jutil.Env, interface{}) (jutil.Object, error)
+}
+
+// javaItem returns a global reference to the Java object for the provided
+// channel item, which is either a goItem or a global reference itself.
+func javaItem(item interface{}) (jutil.Object, error) {
+	i, ok := item.(goItem)
+	if !ok {
+		return item.(jutil.Object), nil
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jItem, err := i.convert(env, i.value)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	// Must grab a global reference as we free up the env and all local references that come
+	// along with it.
+	return jutil.NewGlobalRef(env, jItem), nil // Un-refed by InputChannelImpl_nativeRecv
+}
+
+// releaseItem releases a channel item that will never be handed to Java.
+func releaseItem(item interface{}) {
+	if jItem, ok := item.(jutil.Object); ok {
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		jutil.DeleteGlobalRef(env, jItem)
+	}
+}
+
+// copyItem returns the i-th copy of the provided channel item, for a tee.
+// All copies but the first hold their own global references.
+func copyItem(item interface{}, i int) interface{} {
+	jItem, ok := item.(jutil.Object)
+	if !ok || i == 0 {
+		return item
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	return jutil.NewGlobalRef(env, jItem)
+}
+
+// inputChannel is the Go state of a Java InputChannel object.
+type inputChannel struct {
+	ctx     *context.T
+	cancel  func()
+	src     source // produces goItems or global references
+	values  bool   // true if src produces goItems only
+	ownsCtx bool   // true if ctx is used by this channel alone
+
+	mu        sync.Mutex
+	started   bool
+	canceled  bool
+	takenOver bool        // true if the items are handed over to a combinator
+	prefetch  *prefetcher // nil if prefetching is off
+}
+
+// next returns the next item of the channel.
+func (c *inputChannel) next() (jutil.Object, error) {
+	c.mu.Lock()
+	c.started = true
+	canceled, p := c.canceled, c.prefetch
+	c.mu.Unlock()
+	if canceled {
+		return jutil.NullObject, verror.NewErrEndOfFile(c.ctx)
+	}
+	var item interface{}
+	var err error
+	if p == nil {
+		item, err = c.src()
+	} else {
+		item, err = p.next()
+	}
+	if err == errCanceled || err == io.EOF {
+		return jutil.NullObject, verror.NewErrEndOfFile(c.ctx)
+	}
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	return javaItem(item)
+}
+
+// takeOver hands the items of the channel over to a combinator, which
+// becomes responsible for stopping the channel's producer.  It must be
+// invoked before the first item is received, and the channel can no longer be
+// received from afterwards.
+func (c *inputChannel) takeOver() (source, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started || c.canceled || c.prefetch != nil {
+		return nil, fmt.Errorf("channels must be combined before receiving from them and before turning on prefetching")
+	}
+	c.started, c.canceled, c.takenOver = true, true, true
+	return c.src, nil
+}
+
+// handBack undoes takeOver, for combinators that fail to be created.
+func (c *inputChannel) handBack() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started, c.canceled, c.takenOver = false, false, false
+}
+
+// stop stops the producer of the channel.
+func (c *inputChannel) stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// setPrefetch turns on prefetching of up to size items.  It must be invoked
+// before the first item is received.
+func (c *inputChannel) setPrefetch(size int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started || c.prefetch != nil {
+		return fmt.Errorf("prefetching must be configured once, before receiving from the channel")
+	}
+	c.prefetch = newPrefetcher(size, c.src, releaseItem)
+	return nil
+}
+
+// close cancels the channel: all prefetched items are released and all
+// subsequent receives return verror.ErrEndOfFile.  If stopProducer is true,
+// or the channel owns its context, the channel's context is canceled as well,
+// which stops the producer (unless a combinator took the channel over).
+func (c *inputChannel) close(stopProducer bool) {
+	c.mu.Lock()
+	c.canceled = true
+	p, takenOver := c.prefetch, c.takenOver
+	c.mu.Unlock()
+	if !takenOver && (stopProducer || c.ownsCtx) {
+		c.stop()
+	}
+	if p != nil {
+		p.cancel()
+	}
+}
+
+// takeOver hands the items of all provided channels over to a combinator,
+// returning their sources.  If any of the channels can't be taken over, none
+// of them is.
+func takeOver(inputs []*inputChannel) ([]source, error) {
+	srcs := make([]source, len(inputs))
+	for i, in := range inputs {
+		src, err := in.takeOver()
+		if err != nil {
+			for _, in := range inputs[:i] {
+				in.handBack()
+			}
+			return nil, err
+		}
+		srcs[i] = src
+	}
+	return srcs, nil
+}
+
+// combine takes over the provided channels and creates a Java InputChannel
+// whose items are produced by the source that build returns for the channels'
+// sources.  The new channel uses its own context, derived from ctx, whose
+// done channel is passed to build; closing the new channel cancels that
+// context and stops the producers of the combined channels.
+func combine(env jutil.Env, ctx *context.T, inputs []*inputChannel, build func(done <-chan struct{}, srcs []source) source) (jutil.Object, error) {
+	srcs, err := takeOver(inputs)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	ch := &inputChannel{
+		ctx:     ctx,
+		src:     build(ctx.Done(), srcs),
+		values:  true,
+		ownsCtx: true,
+		cancel: func() {
+			cancel()
+			for _, in := range inputs {
+				in.stop()
+			}
+		},
+	}
+	for _, in := range inputs {
+		ch.values = ch.values && in.values
+	}
+	jChannel, err := newJavaInputChannel(env, ch)
+	if err != nil {
+		ch.cancel()
+		return jutil.NullObject, err
+	}
+	return jChannel, nil
+}
+
+// tee takes over the provided channel and creates n Java InputChannels, each
+// of which produces all items of the provided channel.  Each of the new
+// channels uses its own context, derived from ctx; closing one of them only
+// detaches it from the others, and the producer of the provided channel is
+// stopped once all of them are closed.
+func tee(env jutil.Env, ctx *context.T, input *inputChannel, n int) ([]jutil.Object, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of tee channels: %d", n)
+	}
+	src, err := input.takeOver()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var mu sync.Mutex
+	open := n
+	detach := make([]chan struct{}, n)
+	detachRecv := make([]<-chan struct{}, n)
+	for i := range detach {
+		detach[i] = make(chan struct{})
+		detachRecv[i] = detach[i]
+	}
+	srcs := teeSources(src, teeBuffer, copyItem, ctx.Done(), releaseItem, detachRecv...)
+	chans := make([]*inputChannel, n)
+	for i := range chans {
+		teeCtx, teeCancel := context.WithCancel(ctx)
+		var once sync.Once
+		detached := detach[i]
+		chans[i] = &inputChannel{
+			ctx:     teeCtx,
+			src:     srcs[i],
+			values:  input.values,
+			ownsCtx: true,
+			cancel: func() {
+				teeCancel()
+				once.Do(func() {
+					close(detached)
+					mu.Lock()
+					open--
+					last := open == 0
+					mu.Unlock()
+					if last {
+						cancel()
+						input.stop()
+					}
+				})
+			},
+		}
+	}
+	jChannels := make([]jutil.Object, n)
+	for i, ch := range chans {
+		if jChannels[i], err = newJavaInputChannel(env, ch); err != nil {
+			for _, ch := range chans {
+				ch.cancel()
+			}
+			return nil, err
+		}
+	}
+	return jChannels, nil
+}
+
 // JavaOutputChannel creates a new Java OutputChannel object given the provided Go convert, send
 // and close functions. Send is invoked with the result of convert, which must be non-blocking.
 func JavaOutputChannel(env jutil.Env, ctx *context.T, ctxCancel func(), convert func(jutil.Object) (interface{}, error), send func(interface{}) error, close func() error) (jutil.Object, error) {
@@ -9,6 +9,7 @@ package discovery
 import (
 	"unsafe"
 
+	"v.io/v23/context"
 	"v.io/v23/discovery"
 	"v.io/v23/security"
 	"v.io/v23/verror"
@@ -163,30 +164,28 @@ func Java_io_v_impl_google_lib_discovery_DiscoveryImpl_nativeScan(jenv *C.JNIEnv
 	d := *(*discovery.T)(jutil.GoRefValue(jutil.Ref(goRef)))
 	query := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jQuery))))
 
+	// The scan runs until its context is canceled, which canceling the channel does.
+	ctx, cancel := context.WithCancel(ctx)
 	scanCh, err := d.Scan(ctx, query)
 	if err != nil {
+		cancel()
 		jutil.JThrowV(env, err)
 		return nil
 	}
 
-	jChannel, err := jchannel.JavaInputChannel(env, ctx, nil, func() (jutil.Object, error) {
+	// Updates are converted to Java only once they are received by Java, so that they can be
+	// filtered by the channel combinators first.
+	jChannel, err := jchannel.JavaValueInputChannel(env, ctx, cancel, func() (interface{}, error) {
 		update, ok := <-scanCh
 		if !ok {
-			return jutil.NullObject, verror.NewErrEndOfFile(ctx)
+			return nil, verror.NewErrEndOfFile(ctx)
 		}
-
-		env, freeFunc := jutil.GetEnv()
-		defer freeFunc()
-
-		jUpdate, err := javaUpdate(env, update)
-		if err != nil {
-			return jutil.NullObject, err
-		}
-		// Must grab a global reference as we free up the env and all local references that come
-		// along with it.
-		return jutil.NewGlobalRef(env, jUpdate), nil // un-refed by InputChannelImpl_nativeRecv
+		return scanUpdate{update.IsLost(), update.Advertisement(), update}, nil
+	}, func(env jutil.Env, value interface{}) (jutil.Object, error) {
+		return javaUpdate(env, value.(scanUpdate).update)
 	})
 	if err != nil {
+		cancel()
 		jutil.JThrowV(env, err)
 		return nil
 	}
@@ -31,6 +31,15 @@ func JavaDiscovery(env jutil.Env, d discovery.T) (jutil.Object, error) {
 	return jDiscovery, nil
 }
 
+// scanUpdate is a discovery update as seen by the channel combinators, which
+// filter updates on their fields.
+type scanUpdate struct {
+	IsLost        bool
+	Advertisement discovery.Advertisement
+
+	update discovery.Update // the update converted to Java
+}
+
 // javaUpdate converts a Go update instance into a Java update instance.
 func javaUpdate(env jutil.Env, update discovery.Update) (jutil.Object, error) {
 	jAd, err := jutil.JVomCopy(env, update.Advertisement(), jAdvertisementClass)
@@ -33,6 +33,8 @@ var (
 	JMountEntryClass jutil.Class
 	// Global reference for io.v.v23.security.access.Permissions
 	jPermissionsClass jutil.Class
+	// Global reference for io.v.v23.Options class.
+	jOptionsClass jutil.Class
 )
 
 // Init initializes the JNI code with the given Java environment. This method
@@ -55,6 +57,10 @@ func Init(env jutil.Env) error {
 	if err != nil {
 		return err
 	}
+	jOptionsClass, err = jutil.JFindClass(env, "io/v/v23/Options")
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -87,25 +93,20 @@ func Java_io_v_impl_google_namespace_NamespaceImpl_nativeGlob(jenv *C.JNIEnv, jN
 		globChannel, globError = n.Glob(ctx, pattern, opts...)
 		close(globDone)
 	}()
-	jChannel, err := jchannel.JavaInputChannel(env, ctx, cancel, func() (jutil.Object, error) {
-		// A few blocking calls below - don't call GetEnv() before they complete.
+	// Glob replies are converted to Java only once they are received by Java, so that they
+	// can be filtered by the channel combinators first.
+	jChannel, err := jchannel.JavaValueInputChannel(env, ctx, cancel, func() (interface{}, error) {
 		<-globDone
 		if globError != nil {
-			return jutil.NullObject, globError
+			return nil, globError
 		}
 		globReply, ok := <-globChannel
 		if !ok {
-			return jutil.NullObject, verror.NewErrEndOfFile(ctx)
+			return nil, verror.NewErrEndOfFile(ctx)
 		}
-		env, freeFunc := jutil.GetEnv()
-		defer freeFunc()
-		jReply, err := jutil.JVomCopy(env, globReply, jGlobReplyClass)
-		if err != nil {
-			return jutil.NullObject, err
-		}
-		// Must grab a global reference as we free up the env and all local references that come
-		// along with it.
-		return jutil.NewGlobalRef(env, jReply), nil // Un-refed by InputChannelImpl_nativeRecv
+		return globReply, nil
+	}, func(env jutil.Env, globReply interface{}) (jutil.Object, error) {
+		return jutil.JVomCopy(env, globReply, jGlobReplyClass)
 	})
 	if err != nil {
 		jutil.JThrowV(env, err)
@@ -243,6 +244,37 @@ func Java_io_v_impl_google_namespace_NamespaceImpl_nativeResolve(jenv *C.JNIEnv,
 	})
 }
 
+func doShallowResolve(n namespace.T, context *context.T, name string, options []naming.NamespaceOpt) (jutil.Object, error) {
+	entry, err := n.ShallowResolve(context, name, options...)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jEntry, err := jutil.JVomCopy(env, entry, JMountEntryClass)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	// Must grab a global reference as we free up the env and all local references that come along
+	// with it.
+	return jutil.NewGlobalRef(env, jEntry), nil // Un-refed in DoAsyncCall
+}
+
+//export Java_io_v_impl_google_namespace_NamespaceImpl_nativeShallowResolve
+func Java_io_v_impl_google_namespace_NamespaceImpl_nativeShallowResolve(jenv *C.JNIEnv, jNamespaceClass C.jclass, goRef C.jlong, jContext C.jobject, jName C.jstring, jOptions C.jobject, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	n := *(*namespace.T)(jutil.GoRefValue(jutil.Ref(goRef)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	context, name, options, err := resolveArgs(env, jName, jContext, jOptions)
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
+		return doShallowResolve(n, context, name, options)
+	})
+}
+
 func resolveToMountTableArgs(env jutil.Env, jContext, jOptions C.jobject, jName C.jstring) (context *context.T, options []naming.NamespaceOpt, name string, err error) {
 	context, _, err = jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
 	if err != nil {
@@ -308,6 +340,23 @@ func Java_io_v_impl_google_namespace_NamespaceImpl_nativeSetCachingPolicy(jenv *
 	return C.JNI_TRUE
 }
 
+//export Java_io_v_impl_google_namespace_NamespaceImpl_nativeCacheCtl
+func Java_io_v_impl_google_namespace_NamespaceImpl_nativeCacheCtl(jenv *C.JNIEnv, jNamespaceClass C.jclass, goRef C.jlong, jCtls C.jobject) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	n := *(*namespace.T)(jutil.GoRefValue(jutil.Ref(goRef)))
+	ctls, err := goCacheCtls(env, jutil.Object(uintptr(unsafe.Pointer(jCtls))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jCurrent, err := javaCacheCtls(env, n.CacheCtl(ctls...))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jCurrent))
+}
+
 //export Java_io_v_impl_google_namespace_NamespaceImpl_nativeFlushCacheEntry
 func Java_io_v_impl_google_namespace_NamespaceImpl_nativeFlushCacheEntry(jenv *C.JNIEnv, jNamespaceClass C.jclass, goRef C.jlong, jContext C.jobject, jName C.jstring) C.jboolean {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
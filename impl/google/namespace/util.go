@@ -13,6 +13,7 @@ import (
 	"v.io/v23/security"
 
 	jutil "v.io/x/jni/util"
+	jsecurity "v.io/x/jni/v23/security"
 )
 
 // #include "jni.h"
@@ -33,29 +34,107 @@ func JavaNamespace(env jutil.Env, namespace namespace.T) (jutil.Object, error) {
 	return jNamespace, nil
 }
 
+const (
+	replaceMountKey             = "io.v.v23.naming.REPLACE_MOUNT"
+	servesMountTableKey         = "io.v.v23.naming.SERVES_MOUNT_TABLE"
+	isLeafKey                   = "io.v.v23.naming.IS_LEAF"
+	preresolvedKey              = "io.v.v23.naming.PRERESOLVED"
+	skipServerEndpointAuthKey   = "io.v.v23.SKIP_SERVER_ENDPOINT_AUTHORIZATION"
+	nameResolutionAuthorizerKey = "io.v.v23.NAME_RESOLUTION_AUTHORIZER"
+	noRetryKey                  = "io.v.v23.NO_RETRY"
+
+	disableCacheKey = "io.v.v23.naming.DISABLE_CACHE"
+)
+
 func goNamespaceOptions(env jutil.Env, jOptions jutil.Object) ([]naming.NamespaceOpt, error) {
 	var opts []naming.NamespaceOpt
-	r, err := jutil.GetBooleanOption(env, jOptions, "io.v.v23.naming.REPLACE_MOUNT")
+	r, err := jutil.GetBooleanOption(env, jOptions, replaceMountKey)
 	if err != nil {
 		return nil, err
 	}
 	opts = append(opts, naming.ReplaceMount(r))
-	s, err := jutil.GetBooleanOption(env, jOptions, "io.v.v23.naming.SERVES_MOUNT_TABLE")
+	s, err := jutil.GetBooleanOption(env, jOptions, servesMountTableKey)
 	if err != nil {
 		return nil, err
 	}
 	opts = append(opts, naming.ServesMountTable(s))
-	l, err := jutil.GetBooleanOption(env, jOptions, "io.v.v23.naming.IS_LEAF")
+	l, err := jutil.GetBooleanOption(env, jOptions, isLeafKey)
 	if err != nil {
 		return nil, err
 	}
 	opts = append(opts, naming.IsLeaf(l))
-	e, err := jutil.GetBooleanOption(env, jOptions, "io.v.v23.SKIP_SERVER_ENDPOINT_AUTHORIZATION")
+	e, err := jutil.GetBooleanOption(env, jOptions, skipServerEndpointAuthKey)
 	if err != nil {
 		return nil, err
 	}
 	if e {
 		opts = append(opts, options.NameResolutionAuthorizer{security.AllowEveryone()})
+	} else {
+		jAuth, err := jutil.GetOption(env, jOptions, nameResolutionAuthorizerKey)
+		if err != nil {
+			return nil, err
+		}
+		if !jAuth.IsNull() {
+			auth, err := jsecurity.GoAuthorizer(env, jAuth)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, options.NameResolutionAuthorizer{auth})
+		}
+	}
+	jEntry, err := jutil.GetOption(env, jOptions, preresolvedKey)
+	if err != nil {
+		return nil, err
+	}
+	if !jEntry.IsNull() {
+		var entry naming.MountEntry
+		if err := jutil.GoVomCopy(env, jEntry, JMountEntryClass, &entry); err != nil {
+			return nil, err
+		}
+		opts = append(opts, options.Preresolved{&entry})
+	}
+	n, err := jutil.GetBooleanOption(env, jOptions, noRetryKey)
+	if err != nil {
+		return nil, err
+	}
+	if n {
+		opts = append(opts, options.NoRetry{})
 	}
 	return opts, nil
 }
+
+// goCacheCtls converts the provided Java Options into the namespace cache
+// controls they set.  Controls absent from the options are left unchanged.
+func goCacheCtls(env jutil.Env, jCtls jutil.Object) ([]naming.CacheCtl, error) {
+	var ctls []naming.CacheCtl
+	jDisable, err := jutil.GetOption(env, jCtls, disableCacheKey)
+	if err != nil {
+		return nil, err
+	}
+	if !jDisable.IsNull() {
+		d, err := jutil.GetBooleanOption(env, jCtls, disableCacheKey)
+		if err != nil {
+			return nil, err
+		}
+		ctls = append(ctls, naming.DisableCache(d))
+	}
+	return ctls, nil
+}
+
+// javaCacheCtls converts the provided namespace cache controls into Java
+// Options.
+func javaCacheCtls(env jutil.Env, ctls []naming.CacheCtl) (jutil.Object, error) {
+	jCtls, err := jutil.NewObject(env, jOptionsClass, nil)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	for _, ctl := range ctls {
+		switch value := ctl.(type) {
+		case naming.DisableCache:
+			if err := jutil.SetBooleanOption(env, jCtls, disableCacheKey, bool(value)); err != nil {
+				return jutil.NullObject, err
+			}
+		}
+	}
+	return jCtls, nil
+}
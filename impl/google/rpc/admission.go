@@ -0,0 +1,288 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/verror"
+	"v.io/x/ref/lib/stats"
+	"v.io/x/ref/lib/stats/counter"
+
+	jopts "v.io/x/jni/v23/options"
+)
+
+var (
+	errServerBusy       = verror.Register("v.io/x/jni/impl/google/rpc.errServerBusy", verror.RetryBackoff, "{1:}{2:} server is busy, call to {3} rejected")
+	errAdmissionTimeout = verror.Register("v.io/x/jni/impl/google/rpc.errAdmissionTimeout", verror.RetryBackoff, "{1:}{2:} call to {3} timed out after waiting {4} for admission")
+)
+
+// AdmissionLimits bounds the number of calls that a server handles
+// concurrently.  Zero or negative limits mean no limit.
+type AdmissionLimits struct {
+	// MaxConcurrentCalls is the maximum number of calls handled
+	// concurrently across all methods, lookups included.
+	MaxConcurrentCalls int
+	// MaxConcurrentCallsPerMethod is the maximum number of calls handled
+	// concurrently for any single method not listed in MethodLimits.
+	// Lookups don't know the method of the call and aren't subject to it.
+	MaxConcurrentCallsPerMethod int
+	// MethodLimits overrides MaxConcurrentCallsPerMethod for the listed
+	// methods.
+	MethodLimits map[string]int
+	// MaxQueuedCalls is the maximum number of calls waiting to be admitted;
+	// calls that exceed it are rejected.  If it is zero, calls that can't
+	// be admitted immediately are rejected.
+	MaxQueuedCalls int
+	// MaxQueueWait is the longest time a call waits to be admitted, if it
+	// is shorter than the time left until the call's deadline.
+	MaxQueueWait time.Duration
+	// StatsPrefix, if non-empty, is the prefix of the stats counters named
+	// <prefix>/{admitted,queued,rejected,timed-out} that count the calls
+	// in each of these outcomes.
+	StatsPrefix string
+}
+
+// AdmissionStats is a snapshot of the admission control of a server.  The
+// lookup, Prepare and Invoke stages of a call are admitted separately, so a
+// call that goes through all three counts three times.
+type AdmissionStats struct {
+	// Admitted, Queued, Rejected and TimedOut count, respectively, the
+	// calls that were admitted (immediately or after waiting), that had to
+	// wait to be admitted, that were rejected without waiting, and that
+	// gave up waiting because their deadline or MaxQueueWait expired or
+	// they were canceled.
+	Admitted, Queued, Rejected, TimedOut int64
+	// Active and Waiting are the numbers of calls currently handled and
+	// waiting to be admitted, respectively.
+	Active, Waiting int
+}
+
+// admissionLimits returns the admission limits set in the provided server
+// options.  Admission stats are exported under the options' metrics prefix,
+// if any.
+func admissionLimits(opts jopts.ServerInterceptorOpts) AdmissionLimits {
+	limits := AdmissionLimits{
+		MaxConcurrentCalls:          opts.MaxConcurrentCalls,
+		MaxConcurrentCallsPerMethod: opts.MaxConcurrentCallsPerMethod,
+		MethodLimits:                opts.MethodConcurrencyLimits,
+		MaxQueuedCalls:              opts.MaxQueuedCalls,
+		MaxQueueWait:                opts.MaxQueueWait,
+	}
+	if opts.MetricsPrefix != "" {
+		limits.StatsPrefix = naming.Join(opts.MetricsPrefix, "admission")
+	}
+	return limits
+}
+
+// AdmissionInterceptor enforces the provided limits before each lookup,
+// Prepare and Invoke, so that no stage of a call reaches the dispatcher or
+// invoker unless it is admitted.  Each stage holds its admission until it
+// returns.  Calls that can't be admitted right away wait, in arrival order,
+// for the calls in progress to finish; calls that can't wait fail with a
+// RetryBackoff error.
+func AdmissionInterceptor(limits AdmissionLimits) Interceptor {
+	a := newAdmissionController(limits)
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error) {
+			if err := a.admit(ctx, lookupMethod, suffix); err != nil {
+				return nil, nil, err
+			}
+			defer a.release(lookupMethod)
+			return next(ctx, suffix)
+		},
+		Prepare: func(ctx *context.T, method string, numArgs int, next PrepareFunc) ([]interface{}, []*vdl.Value, error) {
+			if err := a.admit(ctx, method, method); err != nil {
+				return nil, nil, err
+			}
+			defer a.release(method)
+			return next(ctx, method, numArgs)
+		},
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error) {
+			if err := a.admit(ctx, method, method); err != nil {
+				return nil, err
+			}
+			defer a.release(method)
+			return next(ctx, call, method, argptrs)
+		},
+	}
+}
+
+// lookupMethod is the method under which lookups are admitted.  Method names
+// are never empty.
+const lookupMethod = ""
+
+// admissionController tracks the calls in progress and the calls waiting to
+// be admitted.  Its invariant is that no waiting call could be admitted.
+type admissionController struct {
+	limits AdmissionLimits
+
+	mu       sync.Mutex
+	active   int
+	byMethod map[string]int // active calls, by method
+	waiters  *list.List     // of *admissionWaiter, in arrival order
+	stats    AdmissionStats
+	counters *admissionCounters // nil if stats aren't exported
+}
+
+type admissionWaiter struct {
+	method   string
+	admitted chan struct{} // closed once the call is admitted
+}
+
+type admissionCounters struct {
+	admitted, queued, rejected, timedOut *counter.Counter
+}
+
+func newAdmissionController(limits AdmissionLimits) *admissionController {
+	a := &admissionController{
+		limits:   limits,
+		byMethod: make(map[string]int),
+		waiters:  list.New(),
+	}
+	if p := limits.StatsPrefix; p != "" {
+		a.counters = &admissionCounters{
+			admitted: stats.NewCounter(naming.Join(p, "admitted")),
+			queued:   stats.NewCounter(naming.Join(p, "queued")),
+			rejected: stats.NewCounter(naming.Join(p, "rejected")),
+			timedOut: stats.NewCounter(naming.Join(p, "timed-out")),
+		}
+	}
+	return a
+}
+
+// snapshot returns the current admission statistics.
+func (a *admissionController) snapshot() AdmissionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.stats
+	s.Active, s.Waiting = a.active, a.waiters.Len()
+	return s
+}
+
+func (a *admissionController) methodLimit(method string) int {
+	if method == lookupMethod {
+		return 0
+	}
+	if limit, ok := a.limits.MethodLimits[method]; ok {
+		return limit
+	}
+	return a.limits.MaxConcurrentCallsPerMethod
+}
+
+// canAdmitLocked returns true iff a call to the provided method can be
+// admitted without exceeding the limits.
+func (a *admissionController) canAdmitLocked(method string) bool {
+	if max := a.limits.MaxConcurrentCalls; max > 0 && a.active >= max {
+		return false
+	}
+	if max := a.methodLimit(method); max > 0 && a.byMethod[method] >= max {
+		return false
+	}
+	return true
+}
+
+func (a *admissionController) acquireLocked(method string) {
+	a.active++
+	a.byMethod[method]++
+	a.stats.Admitted++
+	if a.counters != nil {
+		a.counters.admitted.Incr(1)
+	}
+}
+
+// admit blocks until a call to the provided method can be admitted, or fails
+// if the call can't wait for it.  Errors name the call by the provided name.
+// Calls that are admitted must be released.
+func (a *admissionController) admit(ctx *context.T, method, name string) error {
+	a.mu.Lock()
+	// Given the invariant, no waiting call could use the capacity that
+	// this call needs.
+	if a.canAdmitLocked(method) {
+		a.acquireLocked(method)
+		a.mu.Unlock()
+		return nil
+	}
+	// The call waits for at most MaxQueueWait and at most until its
+	// deadline; it waits indefinitely if it has neither.
+	wait, bounded := a.limits.MaxQueueWait, a.limits.MaxQueueWait > 0
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := deadline.Sub(time.Now()); !bounded || left < wait {
+			wait, bounded = left, true
+		}
+	}
+	if a.waiters.Len() >= a.limits.MaxQueuedCalls || bounded && wait <= 0 {
+		a.stats.Rejected++
+		if a.counters != nil {
+			a.counters.rejected.Incr(1)
+		}
+		a.mu.Unlock()
+		return verror.New(errServerBusy, ctx, name)
+	}
+	w := &admissionWaiter{method: method, admitted: make(chan struct{})}
+	e := a.waiters.PushBack(w)
+	a.stats.Queued++
+	if a.counters != nil {
+		a.counters.queued.Incr(1)
+	}
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if bounded {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	start := time.Now()
+	select {
+	case <-w.admitted:
+		return nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	select {
+	case <-w.admitted:
+		// The call was admitted while it was giving up.
+		return nil
+	default:
+	}
+	a.waiters.Remove(e)
+	a.stats.TimedOut++
+	if a.counters != nil {
+		a.counters.timedOut.Incr(1)
+	}
+	return verror.New(errAdmissionTimeout, ctx, name, time.Since(start))
+}
+
+// release releases an admitted call to the provided method, admitting the
+// waiting calls that the freed capacity allows.
+func (a *admissionController) release(method string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.active--
+	a.byMethod[method]--
+	if a.byMethod[method] == 0 {
+		delete(a.byMethod, method)
+	}
+	for e := a.waiters.Front(); e != nil; {
+		next := e.Next()
+		if w := e.Value.(*admissionWaiter); a.canAdmitLocked(w.method) {
+			a.waiters.Remove(e)
+			a.acquireLocked(w.method)
+			close(w.admitted)
+		}
+		e = next
+	}
+}
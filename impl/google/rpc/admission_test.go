@@ -0,0 +1,193 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/verror"
+)
+
+func TestAdmissionLimits(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	a := newAdmissionController(AdmissionLimits{
+		MaxConcurrentCalls:          4,
+		MaxConcurrentCallsPerMethod: 2,
+		MethodLimits:                map[string]int{"Slow": 1},
+	})
+	for _, method := range []string{"Get", "Get", "Slow"} {
+		if err := a.admit(ctx, method, method); err != nil {
+			t.Fatalf("call to %s wasn't admitted: %v", method, err)
+		}
+	}
+	// Get and Slow are at their limits, so only other methods are admitted.
+	for _, method := range []string{"Get", "Slow"} {
+		if err := a.admit(ctx, method, method); verror.ErrorID(err) != errServerBusy.ID {
+			t.Errorf("call to %s: got error %v, want %v", method, err, errServerBusy.ID)
+		}
+	}
+	if err := a.admit(ctx, "Put", "Put"); err != nil {
+		t.Fatalf("call to Put wasn't admitted: %v", err)
+	}
+	// The server is at its limit.
+	if err := a.admit(ctx, "List", "List"); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("got error %v, want %v", err, errServerBusy.ID)
+	}
+	want := AdmissionStats{Admitted: 4, Rejected: 3, Active: 4}
+	if got := a.snapshot(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestAdmissionQueue(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	a := newAdmissionController(AdmissionLimits{MaxConcurrentCalls: 1, MaxQueuedCalls: 2})
+	if err := a.admit(ctx, "Get", "Get"); err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan string, 2)
+	for _, method := range []string{"First", "Second"} {
+		go func(method string) {
+			if err := a.admit(ctx, method, method); err != nil {
+				t.Errorf("call to %s wasn't admitted: %v", method, err)
+			}
+			admitted <- method
+		}(method)
+		// Wait for the call to be queued, to fix the order of the calls.
+		for a.snapshot().Waiting == 0 || method == "Second" && a.snapshot().Waiting == 1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	// The queue is full.
+	if err := a.admit(ctx, "Third", "Third"); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("got error %v, want %v", err, errServerBusy.ID)
+	}
+	a.release("Get")
+	if got := <-admitted; got != "First" {
+		t.Errorf("got %s admitted first, want First", got)
+	}
+	a.release("First")
+	if got := <-admitted; got != "Second" {
+		t.Errorf("got %s admitted second, want Second", got)
+	}
+	want := AdmissionStats{Admitted: 3, Queued: 2, Rejected: 1, Active: 1}
+	if got := a.snapshot(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+func TestAdmissionTimeout(t *testing.T) {
+	root, cancel := context.RootContext()
+	defer cancel()
+	a := newAdmissionController(AdmissionLimits{MaxConcurrentCalls: 1, MaxQueuedCalls: 10, MaxQueueWait: 10 * time.Millisecond})
+	if err := a.admit(root, "Get", "Get"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.admit(root, "Get", "Get"); verror.ErrorID(err) != errAdmissionTimeout.ID {
+		t.Errorf("got error %v, want %v", err, errAdmissionTimeout.ID)
+	}
+	want := AdmissionStats{Admitted: 1, Queued: 1, TimedOut: 1, Active: 1}
+	if got := a.snapshot(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+
+	// Without MaxQueueWait, calls wait only until their deadline.
+	a = newAdmissionController(AdmissionLimits{MaxConcurrentCalls: 1, MaxQueuedCalls: 10})
+	if err := a.admit(root, "Get", "Get"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancelTimeout := context.WithTimeout(root, time.Millisecond)
+	defer cancelTimeout()
+	if err := a.admit(ctx, "Get", "Get"); verror.ErrorID(err) != errAdmissionTimeout.ID {
+		t.Errorf("got error %v, want %v", err, errAdmissionTimeout.ID)
+	}
+	// Calls whose deadline has passed aren't queued.
+	if err := a.admit(ctx, "Get", "Get"); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("got error %v, want %v", err, errServerBusy.ID)
+	}
+	want = AdmissionStats{Admitted: 1, Queued: 1, Rejected: 1, TimedOut: 1, Active: 1}
+	if got := a.snapshot(); got != want {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+}
+
+// slowDispatcher is an rpc.Dispatcher whose lookups of "slow", and Prepare
+// calls for Slow on the invokers it returns, signal entered and then block
+// until unblock receives.
+type slowDispatcher struct {
+	entered, unblock chan struct{}
+}
+
+func (d slowDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	if suffix == "slow" {
+		d.entered <- struct{}{}
+		<-d.unblock
+	}
+	return slowInvoker{d: d}, nil, nil
+}
+
+type slowInvoker struct {
+	rpc.Invoker
+	d slowDispatcher
+}
+
+func (i slowInvoker) Prepare(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error) {
+	if method == "Slow" {
+		i.d.entered <- struct{}{}
+		<-i.d.unblock
+	}
+	return nil, nil, nil
+}
+
+func TestAdmissionInterceptor(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	sd := slowDispatcher{make(chan struct{}), make(chan struct{})}
+	d := Intercept(sd, AdmissionInterceptor(AdmissionLimits{MaxConcurrentCalls: 1}))
+	done := make(chan error)
+
+	// Lookups are admitted.
+	go func() {
+		_, _, err := d.Lookup(ctx, "slow")
+		done <- err
+	}()
+	<-sd.entered
+	if _, _, err := d.Lookup(ctx, "fast"); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("lookup: got error %v, want %v", err, errServerBusy.ID)
+	}
+	sd.unblock <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// So are Prepare calls, which hold up lookups too.
+	obj, _, err := d.Lookup(ctx, "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := obj.(rpc.Invoker)
+	go func() {
+		_, _, err := inv.Prepare(ctx, "Slow", 0)
+		done <- err
+	}()
+	<-sd.entered
+	if _, _, err := inv.Prepare(ctx, "Fast", 0); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("prepare: got error %v, want %v", err, errServerBusy.ID)
+	}
+	if _, _, err := d.Lookup(ctx, "fast"); verror.ErrorID(err) != errServerBusy.ID {
+		t.Errorf("lookup: got error %v, want %v", err, errServerBusy.ID)
+	}
+	sd.unblock <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
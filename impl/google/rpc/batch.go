@@ -0,0 +1,182 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"v.io/v23/rpc"
+	"v.io/v23/verror"
+)
+
+// recvReadAhead is the number of items a recvBatcher may receive before they
+// are asked for.
+const recvReadAhead = 16
+
+var errBatcherClosed = verror.Register("v.io/x/jni/impl/google/rpc.errBatcherClosed", verror.NoRetry, "{1:}{2:} call has finished")
+
+var (
+	recvBatchersMu sync.Mutex
+	recvBatchers   = make(map[rpc.Stream]*recvBatcher)
+)
+
+// getRecvBatcher returns the batcher receiving from the provided stream, or
+// nil if the stream has none.
+func getRecvBatcher(stream rpc.Stream) *recvBatcher {
+	recvBatchersMu.Lock()
+	defer recvBatchersMu.Unlock()
+	return recvBatchers[stream]
+}
+
+// startRecvBatcher returns the batcher receiving from the provided stream,
+// creating one that receives items with recv if the stream has none.  Once a
+// stream has a batcher, all receives from the stream must go through it, until
+// the call the stream belongs to finishes and stopRecvBatcher is called.
+func startRecvBatcher(stream rpc.Stream, recv func() ([]byte, error)) *recvBatcher {
+	recvBatchersMu.Lock()
+	defer recvBatchersMu.Unlock()
+	b, ok := recvBatchers[stream]
+	if !ok {
+		b = newRecvBatcher(recv)
+		recvBatchers[stream] = b
+	}
+	return b
+}
+
+// stopRecvBatcher closes and forgets the batcher receiving from the provided
+// stream, if any.
+func stopRecvBatcher(stream rpc.Stream) {
+	recvBatchersMu.Lock()
+	b, ok := recvBatchers[stream]
+	delete(recvBatchers, stream)
+	recvBatchersMu.Unlock()
+	if ok {
+		b.close()
+	}
+}
+
+// recvBatcher groups the items received from a stream into batches, so that
+// they can be handed to Java with a single JNI crossing.
+//
+// Items are received by a single goroutine that reads at most recvReadAhead
+// items ahead of the batches handed out; an item that was received but didn't
+// fit into a batch is delivered at the start of the next batch.  Once the
+// batcher is closed, the goroutine stops receiving and drops the item it was
+// receiving, if any.
+type recvBatcher struct {
+	recv      func() ([]byte, error) // receives and encodes a single item
+	once      sync.Once
+	results   chan recvResult
+	closeOnce sync.Once
+	done      chan struct{}
+
+	mu       sync.Mutex
+	buffered *recvResult // received, but not yet delivered
+	err      error       // terminal error, once delivered
+}
+
+type recvResult struct {
+	item []byte
+	err  error
+}
+
+func newRecvBatcher(recv func() ([]byte, error)) *recvBatcher {
+	return &recvBatcher{
+		recv:    recv,
+		results: make(chan recvResult, recvReadAhead),
+		done:    make(chan struct{}),
+	}
+}
+
+func (b *recvBatcher) receive() {
+	for {
+		select {
+		case <-b.done:
+			return
+		default:
+		}
+		item, err := b.recv()
+		select {
+		case b.results <- recvResult{item, err}:
+		case <-b.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// close stops the batcher: no further items are received, and future calls to
+// next, as well as pending calls that haven't received any item yet, fail.
+func (b *recvBatcher) close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// next blocks until at least one item is available and returns a batch of
+// up to maxItems items, whose total size doesn't exceed maxBytes (unless the
+// first item alone does).  After the first item is available, next waits at
+// most window for further items.  Non-positive maxItems and maxBytes mean no
+// limit, though at least one of them should be set.
+//
+// An error is returned only if it prevented the receipt of the first item of
+// the batch; errors encountered later are returned by the next call.  Once an
+// error is returned, all subsequent calls return the same error.
+func (b *recvBatcher) next(maxItems, maxBytes int, window time.Duration) ([][]byte, error) {
+	b.once.Do(func() { go b.receive() })
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.done:
+		return nil, verror.New(errBatcherClosed, nil)
+	default:
+	}
+	var first recvResult
+	switch {
+	case b.buffered != nil:
+		first, b.buffered = *b.buffered, nil
+	case b.err != nil:
+		return nil, b.err
+	default:
+		select {
+		case first = <-b.results:
+		case <-b.done:
+			return nil, verror.New(errBatcherClosed, nil)
+		}
+	}
+	if first.err != nil {
+		b.err = first.err
+		return nil, first.err
+	}
+	items, size := [][]byte{first.item}, len(first.item)
+	var timeout <-chan time.Time
+	for maxItems <= 0 || len(items) < maxItems {
+		var r recvResult
+		select {
+		case r = <-b.results:
+		default:
+			// Nothing is ready yet: start the window, if not already started.
+			if timeout == nil {
+				timer := time.NewTimer(window)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+			select {
+			case r = <-b.results:
+			case <-timeout:
+				return items, nil
+			case <-b.done:
+				return items, nil
+			}
+		}
+		if r.err != nil || (maxBytes > 0 && size+len(r.item) > maxBytes) {
+			b.buffered = &r
+			return items, nil
+		}
+		items, size = append(items, r.item), size+len(r.item)
+	}
+	return items, nil
+}
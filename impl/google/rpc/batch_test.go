@@ -0,0 +1,201 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/v23/rpc"
+	"v.io/v23/verror"
+)
+
+// sliceRecv returns a recv function that returns the provided items, one at
+// a time, and then io.EOF.
+func sliceRecv(items ...string) func() ([]byte, error) {
+	ch := make(chan string, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return func() ([]byte, error) {
+		item, ok := <-ch
+		if !ok {
+			return nil, io.EOF
+		}
+		return []byte(item), nil
+	}
+}
+
+func batchStrings(batch [][]byte) []string {
+	strs := make([]string, len(batch))
+	for i, item := range batch {
+		strs[i] = string(item)
+	}
+	return strs
+}
+
+func TestRecvBatcher(t *testing.T) {
+	b := newRecvBatcher(sliceRecv("a", "b", "c", "dddd", "e"))
+	tests := []struct {
+		maxItems, maxBytes int
+		want               []string
+	}{
+		{2, 0, []string{"a", "b"}},
+		{0, 3, []string{"c"}},    // "dddd" doesn't fit into the byte budget,
+		{0, 3, []string{"dddd"}}, // but is still delivered on its own,
+		{10, 0, []string{"e"}},   // and the EOF that follows "e" is buffered.
+	}
+	for _, test := range tests {
+		batch, err := b.next(test.maxItems, test.maxBytes, time.Minute)
+		if err != nil {
+			t.Fatalf("next(%d, %d) failed: %v", test.maxItems, test.maxBytes, err)
+		}
+		if got := batchStrings(batch); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("next(%d, %d): got %v, want %v", test.maxItems, test.maxBytes, got, test.want)
+		}
+	}
+	if _, err := b.next(10, 0, time.Minute); err != io.EOF {
+		t.Errorf("got error %v, want io.EOF", err)
+	}
+}
+
+func TestRecvBatcherWindow(t *testing.T) {
+	items := make(chan []byte)
+	b := newRecvBatcher(func() ([]byte, error) { return <-items, nil })
+	go func() { items <- []byte("a") }()
+	// The second item never arrives, so the batch is cut by the window.
+	batch, err := b.next(10, 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("next failed: %v", err)
+	}
+	if got, want := batchStrings(batch), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The receive outstanding since the previous batch delivers the next item.
+	go func() { items <- []byte("b") }()
+	if batch, err = b.next(1, 0, 0); err != nil {
+		t.Fatalf("next failed: %v", err)
+	}
+	if got, want := batchStrings(batch), []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRecvBatcherClose(t *testing.T) {
+	items := make(chan []byte)
+	recvs := make(chan struct{}, 10)
+	b := newRecvBatcher(func() ([]byte, error) {
+		recvs <- struct{}{}
+		return <-items, nil
+	})
+	// A pending call without any items fails once the batcher is closed.
+	errc := make(chan error, 1)
+	go func() {
+		_, err := b.next(1, 0, 0)
+		errc <- err
+	}()
+	<-recvs
+	b.close()
+	if err := <-errc; err == nil {
+		t.Errorf("pending next succeeded after close")
+	}
+	// The item that was being received is dropped, and no further items are
+	// received, even though nothing reads them.
+	items <- []byte("a")
+	time.Sleep(10 * time.Millisecond)
+	if n := len(recvs); n != 0 {
+		t.Errorf("got %d receives after close, want 0", n)
+	}
+	if _, err := b.next(1, 0, 0); err == nil {
+		t.Errorf("next succeeded after close")
+	}
+	b.close()
+}
+
+func TestRecvBatcherCloseUnread(t *testing.T) {
+	recvs := make(chan struct{}, 2*recvReadAhead)
+	b := newRecvBatcher(func() ([]byte, error) {
+		recvs <- struct{}{}
+		return []byte("a"), nil
+	})
+	if _, err := b.next(1, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// The receiving goroutine fills the read-ahead and blocks on delivering
+	// the next item, until the batcher is closed.
+	for i := 0; i < recvReadAhead+2; i++ {
+		<-recvs
+	}
+	b.close()
+	time.Sleep(10 * time.Millisecond)
+	if n := len(recvs); n != 0 {
+		t.Errorf("got %d receives after close, want 0", n)
+	}
+}
+
+// fakeStream is an rpc.Stream that is only used as a batcher key.
+type fakeStream struct{ rpc.Stream }
+
+func TestRecvBatcherLifetime(t *testing.T) {
+	stream := &fakeStream{}
+	if b := getRecvBatcher(stream); b != nil {
+		t.Fatalf("stream has a batcher before batched receives were used")
+	}
+	b := startRecvBatcher(stream, sliceRecv("a"))
+	if got := startRecvBatcher(stream, sliceRecv("b")); got != b {
+		t.Errorf("got a new batcher for a stream that has one")
+	}
+	if got := getRecvBatcher(stream); got != b {
+		t.Errorf("got batcher %p, want %p", got, b)
+	}
+	stopRecvBatcher(stream)
+	if got := getRecvBatcher(stream); got != nil {
+		t.Errorf("stream still has a batcher after its call finished")
+	}
+	if _, err := b.next(1, 0, 0); verror.ErrorID(err) != errBatcherClosed.ID {
+		t.Errorf("got error %v, want %v", err, errBatcherClosed.ID)
+	}
+	// Stopping a stream without a batcher is a no-op.
+	stopRecvBatcher(stream)
+}
+
+// The benchmarks below compare the Go-side cost of handing stream items to
+// Java one at a time (one asynchronous call per item) and in batches.  Each
+// handoff stands in for a JNI crossing, so the batched path also saves a
+// crossing per item that these benchmarks don't account for.
+
+func infiniteRecv() ([]byte, error) {
+	return []byte("item"), nil
+}
+
+func BenchmarkRecvPerItem(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		done := make(chan []byte, 1)
+		go func() {
+			item, _ := infiniteRecv()
+			done <- item
+		}()
+		<-done
+	}
+}
+
+func benchmarkRecvBatch(b *testing.B, size int) {
+	r := newRecvBatcher(infiniteRecv)
+	defer r.close()
+	for received := 0; received < b.N; {
+		done := make(chan int, 1)
+		go func() {
+			batch, _ := r.next(size, 0, time.Millisecond)
+			done <- len(batch)
+		}()
+		received += <-done
+	}
+}
+
+func BenchmarkRecvBatch8(b *testing.B)  { benchmarkRecvBatch(b, 8) }
+func BenchmarkRecvBatch64(b *testing.B) { benchmarkRecvBatch(b, 64) }
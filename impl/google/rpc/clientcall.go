@@ -0,0 +1,117 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/verror"
+)
+
+var errCallClosed = verror.Register("v.io/x/jni/impl/google/rpc.errCallClosed", verror.NoRetry, "{1:}{2:} call was closed")
+
+// clientCall is the Go state of a Java ClientCall object.  The call runs
+// with its own context, so that it can be canceled without canceling the
+// context it was started with, and it can be closed, which releases the
+// underlying rpc.ClientCall before the Java object is garbage-collected.
+//
+// clientCall also serves as the rpc.Stream of the Java call's stream.
+type clientCall struct {
+	ctx    *context.T
+	cancel func() // cancels the call's own context
+
+	mu   sync.Mutex
+	call rpc.ClientCall // nil once the call is closed
+}
+
+func newClientCall(ctx *context.T, cancel func(), call rpc.ClientCall) *clientCall {
+	return &clientCall{ctx: ctx, cancel: cancel, call: call}
+}
+
+func (c *clientCall) get() (rpc.ClientCall, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.call == nil {
+		return nil, verror.New(errCallClosed, c.ctx)
+	}
+	return c.call, nil
+}
+
+func (c *clientCall) Send(item interface{}) error {
+	call, err := c.get()
+	if err != nil {
+		return err
+	}
+	return call.Send(item)
+}
+
+func (c *clientCall) Recv(itemptr interface{}) error {
+	call, err := c.get()
+	if err != nil {
+		return err
+	}
+	return call.Recv(itemptr)
+}
+
+func (c *clientCall) CloseSend() error {
+	call, err := c.get()
+	if err != nil {
+		return err
+	}
+	return call.CloseSend()
+}
+
+// Finish finishes the call and releases its context; the call's remote
+// blessings and endpoint remain available until the call is closed.  Items
+// read ahead by the call's stream batcher, if any, are dropped.
+func (c *clientCall) Finish(resultptrs ...interface{}) error {
+	call, err := c.get()
+	if err != nil {
+		return err
+	}
+	stopRecvBatcher(c)
+	defer c.cancel()
+	return call.Finish(resultptrs...)
+}
+
+// Cancel aborts the call, which causes pending and subsequent operations on
+// it to fail.
+func (c *clientCall) Cancel() {
+	c.cancel()
+}
+
+// Close aborts the call, unless it has finished, and releases it.  All
+// subsequent operations on the call fail with errCallClosed.
+func (c *clientCall) Close() {
+	stopRecvBatcher(c)
+	c.cancel()
+	c.mu.Lock()
+	c.call = nil
+	c.mu.Unlock()
+}
+
+// RemoteBlessings returns the blessings of the server, along with the names
+// in them that the client recognizes.
+func (c *clientCall) RemoteBlessings() ([]string, security.Blessings, error) {
+	call, err := c.get()
+	if err != nil {
+		return nil, security.Blessings{}, err
+	}
+	names, blessings := call.RemoteBlessings()
+	return names, blessings, nil
+}
+
+// RemoteEndpoint returns the endpoint of the server.
+func (c *clientCall) RemoteEndpoint() (naming.Endpoint, error) {
+	call, err := c.get()
+	if err != nil {
+		return naming.Endpoint{}, err
+	}
+	return call.Security().RemoteEndpoint(), nil
+}
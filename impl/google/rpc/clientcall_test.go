@@ -0,0 +1,83 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/verror"
+)
+
+// fakeClientCall is an rpc.ClientCall to a server at the provided endpoint.
+type fakeClientCall struct {
+	rpc.ClientCall
+	ep naming.Endpoint
+}
+
+func (c fakeClientCall) Finish(resultptrs ...interface{}) error { return nil }
+func (c fakeClientCall) Security() security.Call {
+	return security.NewCall(&security.CallParams{RemoteEndpoint: c.ep})
+}
+func (c fakeClientCall) RemoteBlessings() ([]string, security.Blessings) {
+	return []string{"server"}, security.Blessings{}
+}
+
+func TestClientCallFinish(t *testing.T) {
+	canceled := 0
+	ep, _ := naming.ParseEndpoint("@6@tcp@127.0.0.1:8000@@@@@@")
+	c := newClientCall(nil, func() { canceled++ }, fakeClientCall{ep: ep})
+	if err := c.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	// Finishing the call releases its context, but not the call itself.
+	if canceled != 1 {
+		t.Errorf("the call's context was canceled %d times, want 1", canceled)
+	}
+	if got, err := c.RemoteEndpoint(); err != nil || got != ep {
+		t.Errorf("got remote endpoint (%v, %v), want (%v, nil)", got, err, ep)
+	}
+	if names, _, err := c.RemoteBlessings(); err != nil || len(names) != 1 || names[0] != "server" {
+		t.Errorf("got remote blessings (%v, %v), want ([server], nil)", names, err)
+	}
+}
+
+func TestClientCallStopsBatcher(t *testing.T) {
+	for _, end := range []func(c *clientCall){
+		func(c *clientCall) { c.Finish() },
+		func(c *clientCall) { c.Close() },
+	} {
+		c := newClientCall(nil, func() {}, fakeClientCall{})
+		startRecvBatcher(c, func() ([]byte, error) { select {} })
+		end(c)
+		if getRecvBatcher(c) != nil {
+			t.Errorf("call still has a batcher after it ended")
+		}
+	}
+}
+
+func TestClientCallClose(t *testing.T) {
+	canceled := 0
+	c := newClientCall(nil, func() { canceled++ }, fakeClientCall{})
+	c.Close()
+	if canceled != 1 {
+		t.Errorf("the call's context was canceled %d times, want 1", canceled)
+	}
+	for name, err := range map[string]error{
+		"Send":      c.Send(nil),
+		"Recv":      c.Recv(nil),
+		"CloseSend": c.CloseSend(),
+		"Finish":    c.Finish(),
+	} {
+		if verror.ErrorID(err) != errCallClosed.ID {
+			t.Errorf("%s on a closed call: got error %v, want %v", name, err, errCallClosed.ID)
+		}
+	}
+	if _, err := c.RemoteEndpoint(); verror.ErrorID(err) != errCallClosed.ID {
+		t.Errorf("got error %v, want %v", err, errCallClosed.ID)
+	}
+}
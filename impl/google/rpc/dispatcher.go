@@ -20,14 +20,35 @@ import (
 // #include "jni.h"
 import "C"
 
-// GoDispatcher creates a new rpc.Dispatcher given the Java Dispatcher object.
-func GoDispatcher(env jutil.Env, jDispatcher jutil.Object) (rpc.Dispatcher, error) {
+// GoDispatcher creates a new rpc.Dispatcher given the Java Dispatcher object
+// and the Java RpcServerOptions it is served with.  The lookup results for
+// suffixes matching the options' cacheable suffix patterns are cached.  The
+// options may be null, in which case no lookup results are cached.
+func GoDispatcher(env jutil.Env, jDispatcher, jOptions jutil.Object) (rpc.Dispatcher, error) {
+	var patterns []string
+	if !jOptions.IsNull() {
+		var err error
+		if patterns, err = jutil.JStringArrayField(env, jOptions, "cacheableSuffixes"); err != nil {
+			return nil, err
+		}
+	}
+	var cache *lookupCache
+	if len(patterns) > 0 {
+		size, err := jutil.JIntField(env, jOptions, "lookupCacheSize")
+		if err != nil {
+			return nil, err
+		}
+		if cache, err = newLookupCache(patterns, size); err != nil {
+			return nil, err
+		}
+	}
 	// Reference Java dispatcher; it will be de-referenced when the go
 	// dispatcher created below is garbage-collected (through the finalizer
 	// callback we setup below).
 	jDispatcher = jutil.NewGlobalRef(env, jDispatcher)
 	d := &dispatcher{
 		jDispatcher: jDispatcher,
+		cache:       cache,
 	}
 	runtime.SetFinalizer(d, func(d *dispatcher) {
 		env, freeFunc := jutil.GetEnv()
@@ -40,9 +61,29 @@ func GoDispatcher(env jutil.Env, jDispatcher jutil.Object) (rpc.Dispatcher, erro
 
 type dispatcher struct {
 	jDispatcher jutil.Object
+	cache       *lookupCache // nil if lookups aren't cached
 }
 
 func (d *dispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	if d.cache == nil || !d.cache.cacheable(suffix) {
+		return d.lookup(ctx, suffix)
+	}
+	if obj, authorizer, ok := d.cache.get(suffix); ok {
+		return obj, authorizer, nil
+	}
+	obj, authorizer, err := d.lookup(ctx, suffix)
+	if err != nil || obj == nil {
+		return obj, authorizer, err
+	}
+	// The invoker will be reused, so memoize its method tags and signatures.
+	if i, ok := obj.(*invoker); ok {
+		i.methods = newMethodCache()
+	}
+	d.cache.put(suffix, obj, authorizer)
+	return obj, authorizer, nil
+}
+
+func (d *dispatcher) lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
 	// Get Java environment.
 	env, freeFunc := jutil.GetEnv()
 	defer freeFunc()
@@ -0,0 +1,358 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+)
+
+// findMethod returns the signature of the provided method among the provided
+// interface signatures.
+func findMethod(sigs []signature.Interface, method string) (signature.Method, error) {
+	var names []string
+	for _, iface := range sigs {
+		for _, m := range iface.Methods {
+			if m.Name == method {
+				return m, nil
+			}
+			names = append(names, m.Name)
+		}
+	}
+	sort.Strings(names)
+	return signature.Method{}, fmt.Errorf("method %q not found; available methods are %v", method, names)
+}
+
+// checkDynamicCall returns an error if the provided method can't be invoked
+// dynamically with numArgs arguments.
+func checkDynamicCall(m signature.Method, numArgs int) error {
+	if m.InStream != nil || m.OutStream != nil {
+		return fmt.Errorf("method %q is streaming, which dynamic calls don't support", m.Name)
+	}
+	if numArgs != len(m.InArgs) {
+		return fmt.Errorf("method %q takes %d arguments, got %d", m.Name, len(m.InArgs), numArgs)
+	}
+	return nil
+}
+
+// dynamicArgs converts the provided arguments to the in-arg types declared by
+// the provided method.
+func dynamicArgs(m signature.Method, args []*vdl.Value) ([]interface{}, error) {
+	if err := checkDynamicCall(m, len(args)); err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, len(args))
+	for i, arg := range args {
+		value := vdl.ZeroValue(m.InArgs[i].Type)
+		if err := vdl.Convert(value, arg); err != nil {
+			return nil, fmt.Errorf("argument %d (%s) of method %q: %v", i, m.InArgs[i].Name, m.Name, err)
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+// dynamicJSONArgs converts the provided JSON-encoded arguments to the in-arg
+// types declared by the provided method.
+func dynamicJSONArgs(m signature.Method, args []string) ([]interface{}, error) {
+	if err := checkDynamicCall(m, len(args)); err != nil {
+		return nil, err
+	}
+	ret := make([]interface{}, len(args))
+	for i, arg := range args {
+		x, err := decodeJSONArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s) of method %q: %v", i, m.InArgs[i].Name, m.Name, err)
+		}
+		value, err := jsonToValue(m.InArgs[i].Type, x)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s) of method %q: %v", i, m.InArgs[i].Name, m.Name, err)
+		}
+		ret[i] = value
+	}
+	return ret, nil
+}
+
+// decodeJSONArg decodes the single JSON value of the provided argument, with
+// numbers decoded as json.Number.  Data following the value is an error.
+func decodeJSONArg(arg string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(arg))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(new(interface{})); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return x, nil
+}
+
+// dynamicResults converts the provided results to the out-arg types declared
+// by the provided method.
+func dynamicResults(m signature.Method, results []*vdl.Value) ([]*vdl.Value, error) {
+	if len(results) != len(m.OutArgs) {
+		return nil, fmt.Errorf("method %q returns %d results, got %d", m.Name, len(m.OutArgs), len(results))
+	}
+	ret := make([]*vdl.Value, len(results))
+	for i, result := range results {
+		ret[i] = vdl.ZeroValue(m.OutArgs[i].Type)
+		if err := vdl.Convert(ret[i], result); err != nil {
+			return nil, fmt.Errorf("result %d (%s) of method %q: %v", i, m.OutArgs[i].Name, m.Name, err)
+		}
+	}
+	return ret, nil
+}
+
+// jsonToValue converts a value decoded from JSON (with numbers decoded as
+// json.Number) to a VDL value of the provided type.  Lists, arrays and sets
+// are represented as JSON arrays, structs and maps as JSON objects, enums as
+// their labels, unions as objects with a single field, and nil optional and
+// any values as null.  Numbers must fit into their type.  Errors name the
+// offending field, element or key, relative to the provided value.
+func jsonToValue(t *vdl.Type, x interface{}) (*vdl.Value, error) {
+	if x == nil {
+		switch t.Kind() {
+		case vdl.Any, vdl.Optional:
+			return vdl.ZeroValue(t), nil
+		}
+		return nil, fmt.Errorf("null is not a valid %v value", t)
+	}
+	switch t.Kind() {
+	case vdl.Any:
+		return vdl.ZeroValue(t).Assign(vdl.ValueOf(jsonToGo(x))), nil
+	case vdl.Optional:
+		elem, err := jsonToValue(t.Elem(), x)
+		if err != nil {
+			return nil, err
+		}
+		return vdl.OptionalValue(elem), nil
+	}
+	v := vdl.ZeroValue(t)
+	switch t.Kind() {
+	case vdl.Bool:
+		b, ok := x.(bool)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		return v.AssignBool(b), nil
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		u, err := strconv.ParseUint(string(n), 10, bitSize(t.Kind()))
+		if err != nil {
+			return nil, jsonNumberError(t, n, err)
+		}
+		return v.AssignUint(u), nil
+	case vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		i, err := strconv.ParseInt(string(n), 10, bitSize(t.Kind()))
+		if err != nil {
+			return nil, jsonNumberError(t, n, err)
+		}
+		return v.AssignInt(i), nil
+	case vdl.Float32, vdl.Float64:
+		n, ok := x.(json.Number)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		f, err := strconv.ParseFloat(string(n), bitSize(t.Kind()))
+		if err != nil {
+			return nil, jsonNumberError(t, n, err)
+		}
+		return v.AssignFloat(f), nil
+	case vdl.String:
+		s, ok := x.(string)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		return v.AssignString(s), nil
+	case vdl.Enum:
+		label, ok := x.(string)
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		if t.EnumIndex(label) < 0 {
+			return nil, fmt.Errorf("%q is not a label of %v", label, t)
+		}
+		return v.AssignEnumLabel(label), nil
+	case vdl.List, vdl.Array:
+		if s, ok := x.(string); ok && t.Elem().Kind() == vdl.Byte {
+			// Byte lists may also be provided as strings.
+			x = stringToBytes(s)
+		}
+		elems, ok := x.([]interface{})
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		if t.Kind() == vdl.Array && len(elems) != t.Len() {
+			return nil, fmt.Errorf("%v takes %d elements, got %d", t, t.Len(), len(elems))
+		}
+		if t.Kind() == vdl.List {
+			v.AssignLen(len(elems))
+		}
+		for i, x := range elems {
+			elem, err := jsonToValue(t.Elem(), x)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %v", i, err)
+			}
+			v.Index(i).Assign(elem)
+		}
+		return v, nil
+	case vdl.Set:
+		keys, ok := x.([]interface{})
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		for _, x := range keys {
+			key, err := jsonToValue(t.Key(), x)
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %v", x, err)
+			}
+			v.AssignSetKey(key)
+		}
+		return v, nil
+	case vdl.Map:
+		fields, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		for k, x := range fields {
+			key, err := jsonToValue(t.Key(), jsonKey(t.Key(), k))
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %v", k, err)
+			}
+			elem, err := jsonToValue(t.Elem(), x)
+			if err != nil {
+				return nil, fmt.Errorf("value of key %q: %v", k, err)
+			}
+			v.AssignMapIndex(key, elem)
+		}
+		return v, nil
+	case vdl.Struct:
+		fields, ok := x.(map[string]interface{})
+		if !ok {
+			return nil, jsonTypeError(t, x)
+		}
+		for name, x := range fields {
+			field, index := t.FieldByName(name)
+			if index < 0 {
+				return nil, fmt.Errorf("%v has no field %q", t, name)
+			}
+			value, err := jsonToValue(field.Type, x)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", name, err)
+			}
+			v.StructField(index).Assign(value)
+		}
+		return v, nil
+	case vdl.Union:
+		fields, ok := x.(map[string]interface{})
+		if !ok || len(fields) != 1 {
+			return nil, fmt.Errorf("%v values must be objects with a single field", t)
+		}
+		for name, x := range fields {
+			field, index := t.FieldByName(name)
+			if index < 0 {
+				return nil, fmt.Errorf("%v has no field %q", t, name)
+			}
+			value, err := jsonToValue(field.Type, x)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", name, err)
+			}
+			v.AssignUnionField(index, value)
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%v values can't be provided as JSON", t)
+}
+
+func jsonTypeError(t *vdl.Type, x interface{}) error {
+	return fmt.Errorf("JSON value %v (of type %T) is not a valid %v value", x, x, t)
+}
+
+// jsonNumberError describes why the provided JSON number couldn't be parsed
+// as a value of the provided numeric type.
+func jsonNumberError(t *vdl.Type, n json.Number, err error) error {
+	if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
+		return fmt.Errorf("%v overflows %v", n, t)
+	}
+	return fmt.Errorf("%v is not a valid %v value", n, t)
+}
+
+// bitSize returns the size in bits of values of the provided numeric kind.
+func bitSize(k vdl.Kind) int {
+	switch k {
+	case vdl.Byte, vdl.Int8:
+		return 8
+	case vdl.Uint16, vdl.Int16:
+		return 16
+	case vdl.Uint32, vdl.Int32, vdl.Float32:
+		return 32
+	}
+	return 64
+}
+
+// jsonKey returns the provided JSON object key as it would be decoded if it
+// were a JSON value, for map keys of non-string types.
+func jsonKey(t *vdl.Type, key string) interface{} {
+	switch t.Kind() {
+	case vdl.Byte, vdl.Uint16, vdl.Uint32, vdl.Uint64, vdl.Int8, vdl.Int16, vdl.Int32, vdl.Int64, vdl.Float32, vdl.Float64:
+		return json.Number(key)
+	case vdl.Bool:
+		if b, err := strconv.ParseBool(key); err == nil {
+			return b
+		}
+	}
+	return key
+}
+
+func stringToBytes(s string) []interface{} {
+	ret := make([]interface{}, len(s))
+	for i := 0; i < len(s); i++ {
+		ret[i] = json.Number(strconv.Itoa(int(s[i])))
+	}
+	return ret
+}
+
+// jsonToGo converts a value decoded from JSON to the Go value of the closest
+// VDL type, for any values: numbers become float64s (or int64s if they are
+// integers), arrays become []interface{}s, and objects become
+// map[string]interface{}s.
+func jsonToGo(x interface{}) interface{} {
+	switch x := x.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case []interface{}:
+		ret := make([]interface{}, len(x))
+		for i, elem := range x {
+			ret[i] = jsonToGo(elem)
+		}
+		return ret
+	case map[string]interface{}:
+		ret := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			ret[k] = jsonToGo(elem)
+		}
+		return ret
+	}
+	return x
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+)
+
+func TestFindMethod(t *testing.T) {
+	sigs := []signature.Interface{
+		{Name: "A", Methods: []signature.Method{{Name: "Get"}, {Name: "Put"}}},
+		{Name: "B", Methods: []signature.Method{{Name: "Delete"}}},
+	}
+	m, err := findMethod(sigs, "Delete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "Delete" {
+		t.Errorf("got method %q, want Delete", m.Name)
+	}
+	if _, err := findMethod(sigs, "List"); err == nil {
+		t.Errorf("found a method that doesn't exist")
+	}
+}
+
+func TestCheckDynamicCall(t *testing.T) {
+	m := signature.Method{Name: "Put", InArgs: []signature.Arg{{Name: "key"}, {Name: "value"}}}
+	if err := checkDynamicCall(m, 2); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkDynamicCall(m, 1); err == nil {
+		t.Errorf("a call with too few arguments was accepted")
+	}
+	m.OutStream = &signature.Arg{}
+	if err := checkDynamicCall(m, 2); err == nil {
+		t.Errorf("a call to a streaming method was accepted")
+	}
+}
+
+func TestJSONToGo(t *testing.T) {
+	var x interface{}
+	dec := json.NewDecoder(strings.NewReader(`{"a": [1, 2.5, "x", true, null]}`))
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"a": []interface{}{int64(1), 2.5, "x", true, nil}}
+	if got := jsonToGo(x); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+type jsonPoint struct {
+	X, Y int32
+}
+
+type jsonShape struct {
+	Name   string
+	Points []jsonPoint
+	Tags   map[string]uint32
+	Center *jsonPoint
+}
+
+func decodeJSON(t *testing.T, data string) interface{} {
+	x, err := decodeJSONArg(data)
+	if err != nil {
+		t.Fatalf("couldn't decode %s: %v", data, err)
+	}
+	return x
+}
+
+func TestDecodeJSONArg(t *testing.T) {
+	for _, arg := range []string{`1`, ` {"a": 1} `, "[1, 2]\n"} {
+		if _, err := decodeJSONArg(arg); err != nil {
+			t.Errorf("decodeJSONArg(%q) failed: %v", arg, err)
+		}
+	}
+	for _, arg := range []string{``, `1 2`, `{}garbage`, `[1] ]`, `"a" "b"`} {
+		if _, err := decodeJSONArg(arg); err == nil {
+			t.Errorf("decodeJSONArg(%q) succeeded, want error", arg)
+		}
+	}
+}
+
+func TestJSONToValue(t *testing.T) {
+	tests := []struct {
+		json string
+		want interface{}
+	}{
+		{`true`, true},
+		{`255`, byte(255)},
+		{`65535`, uint16(math.MaxUint16)},
+		{`4294967295`, uint32(math.MaxUint32)},
+		{`18446744073709551615`, uint64(math.MaxUint64)},
+		{`-128`, int8(math.MinInt8)},
+		{`32767`, int16(math.MaxInt16)},
+		{`-2147483648`, int32(math.MinInt32)},
+		{`-9223372036854775808`, int64(math.MinInt64)},
+		{`1.5`, float32(1.5)},
+		{`1e300`, float64(1e300)},
+		{`"hello"`, "hello"},
+		{`[1, 2, 3]`, []int32{1, 2, 3}},
+		{`"hi"`, []byte("hi")},
+		{`[104, 105]`, []byte("hi")},
+		{`[1, 2]`, [2]uint16{1, 2}},
+		{`["a", "b"]`, map[string]struct{}{"a": {}, "b": {}}},
+		{`{"a": 1, "b": 2}`, map[string]int32{"a": 1, "b": 2}},
+		{`{"1": "a", "2": "b"}`, map[int8]string{1: "a", 2: "b"}},
+		{`{"X": 1, "Y": -1}`, jsonPoint{1, -1}},
+		{`{"X": 1}`, &jsonPoint{X: 1}},
+		{`null`, (*jsonPoint)(nil)},
+		{
+			`{"Name": "square", "Points": [{"X": 0, "Y": 0}, {"X": 1, "Y": 1}], "Tags": {"sides": 4}, "Center": {"X": 1, "Y": 1}}`,
+			jsonShape{
+				Name:   "square",
+				Points: []jsonPoint{{0, 0}, {1, 1}},
+				Tags:   map[string]uint32{"sides": 4},
+				Center: &jsonPoint{1, 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		want := vdl.ValueOf(test.want)
+		got, err := jsonToValue(want.Type(), decodeJSON(t, test.json))
+		if err != nil {
+			t.Errorf("converting %s to %v failed: %v", test.json, want.Type(), err)
+			continue
+		}
+		if !vdl.EqualValue(got, want) {
+			t.Errorf("converting %s to %v: got %v, want %v", test.json, want.Type(), got, want)
+		}
+	}
+}
+
+func TestJSONToValueErrors(t *testing.T) {
+	tests := []struct {
+		t    *vdl.Type
+		json string
+		err  string
+	}{
+		// Numbers that don't fit into their type.
+		{vdl.ByteType, `256`, "256 overflows byte"},
+		{vdl.Uint16Type, `65536`, "65536 overflows uint16"},
+		{vdl.Uint32Type, `4294967296`, "4294967296 overflows uint32"},
+		{vdl.Uint64Type, `18446744073709551616`, "18446744073709551616 overflows uint64"},
+		{vdl.Int8Type, `128`, "128 overflows int8"},
+		{vdl.Int8Type, `-129`, "-129 overflows int8"},
+		{vdl.Int16Type, `32768`, "32768 overflows int16"},
+		{vdl.Int32Type, `-2147483649`, "-2147483649 overflows int32"},
+		{vdl.Int64Type, `9223372036854775808`, "9223372036854775808 overflows int64"},
+		{vdl.Float32Type, `1e39`, "1e39 overflows float32"},
+		// Numbers of the wrong kind.
+		{vdl.Uint32Type, `-1`, "-1 is not a valid uint32 value"},
+		{vdl.Int32Type, `1.5`, "1.5 is not a valid int32 value"},
+		// JSON values of the wrong type.
+		{vdl.BoolType, `1`, "not a valid bool value"},
+		{vdl.StringType, `1`, "not a valid string value"},
+		{vdl.Int32Type, `"1"`, "not a valid int32 value"},
+		{vdl.TypeOf([]int32(nil)), `{}`, "not a valid []int32 value"},
+		{vdl.TypeOf(map[string]int32(nil)), `[]`, "not a valid map[string]int32 value"},
+		{vdl.TypeOf(jsonPoint{}), `[1, 2]`, "is not a valid"},
+		{vdl.TypeOf((*jsonPoint)(nil)), `"point"`, "is not a valid"},
+		{vdl.Int32Type, `null`, "null is not a valid int32 value"},
+		// Errors name the offending field, element or key.
+		{vdl.TypeOf(jsonPoint{}), `{"X": 1, "Z": 2}`, `has no field "Z"`},
+		{vdl.TypeOf(jsonShape{}), `{"Points": [{"X": 1}, {"Y": 2147483648}]}`, "field Points: element 1: field Y: 2147483648 overflows int32"},
+		{vdl.TypeOf(jsonShape{}), `{"Tags": {"sides": -4}}`, `field Tags: value of key "sides": -4 is not a valid uint32 value`},
+		{vdl.TypeOf(jsonShape{}), `{"Center": {"X": "1"}}`, "field Center: field X: JSON value 1 (of type string) is not a valid int32 value"},
+		{vdl.TypeOf(map[int8]string(nil)), `{"300": "a"}`, `key "300": 300 overflows int8`},
+		{vdl.TypeOf(map[uint16]struct{}(nil)), `[1, 70000]`, "key 70000: 70000 overflows uint16"},
+		{vdl.TypeOf([2]byte{}), `[1, 2, 3]`, "takes 2 elements, got 3"},
+	}
+	for _, test := range tests {
+		_, err := jsonToValue(test.t, decodeJSON(t, test.json))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("converting %s to %v: got error %v, want one containing %q", test.json, test.t, err, test.err)
+		}
+	}
+}
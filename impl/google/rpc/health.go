@@ -0,0 +1,229 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/verror"
+)
+
+// HealthSuffix is the suffix at which servers serve their health service.
+// Lookups for it (and for suffixes under it) never reach the server's own
+// dispatcher.
+const HealthSuffix = "_health"
+
+var (
+	errNotLive  = verror.Register("v.io/x/jni/impl/google/rpc.errNotLive", verror.NoRetry, "{1:}{2:} server is {3}")
+	errNotReady = verror.Register("v.io/x/jni/impl/google/rpc.errNotReady", verror.RetryBackoff, "{1:}{2:} server isn't ready: {3}")
+)
+
+// HealthStatus is the health of a server, as reported by its health service.
+type HealthStatus struct {
+	// State is the state of the server: "not started", "active",
+	// "stopping" or "stopped".
+	State string
+	// Live is true iff the server is active.
+	Live bool
+	// Ready is true iff the server is live, marked ready, has at least one
+	// endpoint and has published all of its names without errors.
+	Ready bool
+	// NotReady lists the reasons the server isn't ready, if any.
+	NotReady []string
+	// Endpoints are the endpoints the server is reachable at.
+	Endpoints []string
+	// ListenErrors, MountErrors and ProxyErrors describe the errors
+	// reported in the server's status.
+	ListenErrors, MountErrors, ProxyErrors []string
+}
+
+// Health tracks the health of a single server.  Its readiness is controlled
+// by its owner (e.g., the Java application); everything else is derived from
+// the status of the server.
+type Health struct {
+	mu     sync.Mutex
+	ready  bool
+	server rpc.Server // nil until attached
+}
+
+// NewHealth creates the health of a server that isn't created yet, with the
+// provided initial readiness.
+func NewHealth(ready bool) *Health {
+	return &Health{ready: ready}
+}
+
+// Dispatcher returns a dispatcher that serves the health service at
+// HealthSuffix and dispatches all other suffixes to d.  If auth is non-nil,
+// it authorizes all calls to the health service.  Otherwise, everyone may
+// call Live and Ready, while Status, which reveals the server's endpoints and
+// errors, is guarded by the authorizer servers apply to objects that don't
+// have one (i.e., security.DefaultAuthorizer).
+func (h *Health) Dispatcher(d rpc.Dispatcher, auth security.Authorizer) (rpc.Dispatcher, error) {
+	if auth == nil {
+		auth = healthAuthorizer{security.DefaultAuthorizer()}
+	}
+	hd, err := ObjectFactory(healthServer{h}, auth)(nil)
+	if err != nil {
+		return nil, err
+	}
+	return newCompositeDispatcher(d, map[string]rpc.Dispatcher{HealthSuffix: hd})
+}
+
+// healthAuthorizer authorizes everyone to call the Live and Ready methods of
+// the health service, and delegates the authorization of all other methods.
+type healthAuthorizer struct {
+	status security.Authorizer
+}
+
+func (a healthAuthorizer) Authorize(ctx *context.T, call security.Call) error {
+	switch call.Method() {
+	case "Live", "Ready":
+		return nil
+	}
+	return a.status.Authorize(ctx, call)
+}
+
+var (
+	healthMu       sync.Mutex
+	healthByServer = make(map[rpc.Server]*Health)
+)
+
+// Attach attaches the health to the provided server, whose status it reports
+// from now on, and makes it available through ServerHealth until the server
+// is closed.  Attaching a nil health is a no-op.
+func (h *Health) Attach(server rpc.Server) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.server = server
+	h.mu.Unlock()
+	healthMu.Lock()
+	healthByServer[server] = h
+	healthMu.Unlock()
+	go func() {
+		<-server.Closed()
+		healthMu.Lock()
+		delete(healthByServer, server)
+		healthMu.Unlock()
+	}()
+}
+
+// ServerHealth returns the health attached to the provided server, or nil if
+// the server doesn't serve a health service.
+func ServerHealth(server rpc.Server) *Health {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	return healthByServer[server]
+}
+
+// SetReady marks the server as ready or not ready.
+func (h *Health) SetReady(ready bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = ready
+}
+
+// Status returns the current health of the server.
+func (h *Health) Status() HealthStatus {
+	h.mu.Lock()
+	ready, server := h.ready, h.server
+	h.mu.Unlock()
+	if server == nil {
+		return HealthStatus{State: "not started", NotReady: []string{"server is not started"}}
+	}
+	return healthStatus(server.Status(), ready)
+}
+
+func healthStatus(status rpc.ServerStatus, ready bool) HealthStatus {
+	var h HealthStatus
+	h.State = serverStateName(status.State)
+	h.Live = status.State == rpc.ServerActive
+	for _, ep := range status.Endpoints {
+		h.Endpoints = append(h.Endpoints, ep.String())
+	}
+	for addr, err := range status.ListenErrors {
+		h.ListenErrors = append(h.ListenErrors, fmt.Sprintf("%s/%s: %v", addr.Protocol, addr.Address, err))
+	}
+	publishing := false
+	for _, e := range status.PublisherStatus {
+		if e.LastState != e.DesiredState {
+			publishing = true
+		}
+		if e.LastMountErr != nil {
+			h.MountErrors = append(h.MountErrors, fmt.Sprintf("%s at %s: %v", e.Name, e.Server, e.LastMountErr))
+		}
+	}
+	for proxy, err := range status.ProxyErrors {
+		h.ProxyErrors = append(h.ProxyErrors, fmt.Sprintf("%s: %v", proxy, err))
+	}
+	sort.Strings(h.ListenErrors)
+	sort.Strings(h.MountErrors)
+	sort.Strings(h.ProxyErrors)
+
+	if !h.Live {
+		h.NotReady = append(h.NotReady, "server is "+h.State)
+	}
+	if !ready {
+		h.NotReady = append(h.NotReady, "server marked not ready")
+	}
+	if len(h.Endpoints) == 0 {
+		h.NotReady = append(h.NotReady, "server has no endpoints")
+	}
+	if publishing {
+		h.NotReady = append(h.NotReady, "server is still publishing some names")
+	}
+	if len(h.MountErrors) > 0 {
+		h.NotReady = append(h.NotReady, "server failed to mount some names")
+	}
+	h.Ready = len(h.NotReady) == 0
+	return h
+}
+
+func serverStateName(state rpc.ServerState) string {
+	switch state {
+	case rpc.ServerActive:
+		return "active"
+	case rpc.ServerStopping:
+		return "stopping"
+	case rpc.ServerStopped:
+		return "stopped"
+	}
+	return fmt.Sprintf("unknown (%d)", state)
+}
+
+// healthServer implements the health service.
+type healthServer struct {
+	h *Health
+}
+
+// Live succeeds iff the server is live.
+func (s healthServer) Live(ctx *context.T, call rpc.ServerCall) error {
+	h := s.h.Status()
+	if !h.Live {
+		return verror.New(errNotLive, ctx, h.State)
+	}
+	return nil
+}
+
+// Ready succeeds iff the server is ready.
+func (s healthServer) Ready(ctx *context.T, call rpc.ServerCall) error {
+	h := s.h.Status()
+	if !h.Ready {
+		return verror.New(errNotReady, ctx, strings.Join(h.NotReady, ", "))
+	}
+	return nil
+}
+
+// Status returns the health of the server.
+func (s healthServer) Status(ctx *context.T, call rpc.ServerCall) (HealthStatus, error) {
+	return s.h.Status(), nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/verror"
+)
+
+// fakeServer is an rpc.Server with the provided status.
+type fakeServer struct {
+	rpc.Server
+	status rpc.ServerStatus
+	closed chan struct{}
+}
+
+func (s *fakeServer) Status() rpc.ServerStatus { return s.status }
+func (s *fakeServer) Closed() <-chan struct{}  { return s.closed }
+
+func TestHealthStatus(t *testing.T) {
+	ep, _ := naming.ParseEndpoint("@6@tcp@127.0.0.1:8000@@@@@@")
+	s := &fakeServer{closed: make(chan struct{})}
+	h := NewHealth(false)
+	hs := healthServer{h}
+	if err := hs.Live(nil, nil); verror.ErrorID(err) != errNotLive.ID {
+		t.Errorf("got error %v before the server started, want %v", err, errNotLive.ID)
+	}
+
+	h.Attach(s)
+	if got := ServerHealth(s); got != h {
+		t.Errorf("got health %p attached to the server, want %p", got, h)
+	}
+	if err := hs.Live(nil, nil); err != nil {
+		t.Errorf("server isn't live: %v", err)
+	}
+	want := []string{"server marked not ready", "server has no endpoints"}
+	if got := h.Status(); got.Ready || !reflect.DeepEqual(got.NotReady, want) {
+		t.Errorf("got status %+v, want not ready because %v", got, want)
+	}
+
+	h.SetReady(true)
+	s.status.Endpoints = []naming.Endpoint{ep}
+	s.status.ListenErrors = map[struct{ Protocol, Address string }]error{{"bt", "ff:ff"}: errors.New("no adapter")}
+	if err := hs.Ready(nil, nil); err != nil {
+		t.Errorf("server isn't ready: %v", err)
+	}
+	got, _ := hs.Status(nil, nil)
+	if want := []string{"bt/ff:ff: no adapter"}; !reflect.DeepEqual(got.ListenErrors, want) {
+		t.Errorf("got listen errors %v, want %v", got.ListenErrors, want)
+	}
+
+	s.status.PublisherStatus = []rpc.PublisherEntry{{Name: "a", Server: ep.String(), LastMountErr: errors.New("denied")}}
+	if err := hs.Ready(nil, nil); verror.ErrorID(err) != errNotReady.ID {
+		t.Errorf("got error %v with mount errors, want %v", err, errNotReady.ID)
+	}
+	s.status.State = rpc.ServerStopping
+	if err := hs.Live(nil, nil); verror.ErrorID(err) != errNotLive.ID {
+		t.Errorf("got error %v while stopping, want %v", err, errNotLive.ID)
+	}
+
+	close(s.closed)
+	for start := time.Now(); ServerHealth(s) != nil; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("health is still attached to the closed server")
+		}
+	}
+}
+
+func TestHealthDispatcher(t *testing.T) {
+	var trace []string
+	d, err := NewHealth(true).Dispatcher(fakeDispatcher{&trace}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{HealthSuffix, "a/b"} {
+		if _, _, err := d.Lookup(nil, suffix); err != nil {
+			t.Errorf("Lookup(%q) failed: %v", suffix, err)
+		}
+	}
+	if want := []string{"lookup a/b"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace %v, want %v", trace, want)
+	}
+}
+
+// methodAuthorizer authorizes calls to its methods only.
+type methodAuthorizer map[string]bool
+
+func (a methodAuthorizer) Authorize(ctx *context.T, call security.Call) error {
+	if !a[call.Method()] {
+		return errors.New("not authorized")
+	}
+	return nil
+}
+
+func TestHealthAuthorizer(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	lookupAuth := func(auth security.Authorizer) security.Authorizer {
+		var trace []string
+		d, err := NewHealth(true).Dispatcher(fakeDispatcher{&trace}, auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, auth, err = d.Lookup(ctx, HealthSuffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth
+	}
+
+	// The provided authorizer guards the whole health service.
+	custom := methodAuthorizer{"Status": true}
+	if got := lookupAuth(custom); !reflect.DeepEqual(got, custom) {
+		t.Errorf("got authorizer %v, want the provided one", got)
+	}
+
+	// Otherwise, only Status is guarded, by the default authorizer.
+	if got, want := lookupAuth(nil), (healthAuthorizer{security.DefaultAuthorizer()}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got authorizer %#v, want %#v", got, want)
+	}
+	auth := healthAuthorizer{methodAuthorizer{}}
+	for method, allowed := range map[string]bool{"Live": true, "Ready": true, "Status": false, "__Signature": false} {
+		err := auth.Authorize(ctx, security.NewCall(&security.CallParams{Method: method}))
+		if got := err == nil; got != allowed {
+			t.Errorf("call to %s: got authorized %v, want %v", method, got, allowed)
+		}
+	}
+}
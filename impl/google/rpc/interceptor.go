@@ -0,0 +1,322 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/verror"
+	"v.io/x/ref/lib/stats"
+	"v.io/x/ref/lib/stats/counter"
+
+	jopts "v.io/x/jni/v23/options"
+)
+
+var errRateLimited = verror.Register("v.io/x/jni/impl/google/rpc.errRateLimited", verror.RetryBackoff, "{1:}{2:} server rate limit exceeded")
+
+// LookupFunc performs (the rest of) a dispatcher lookup.
+type LookupFunc func(ctx *context.T, suffix string) (interface{}, security.Authorizer, error)
+
+// PrepareFunc performs (the rest of) an invoker Prepare call.
+type PrepareFunc func(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error)
+
+// InvokeFunc performs (the rest of) an invoker Invoke call.
+type InvokeFunc func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) ([]interface{}, error)
+
+// Interceptor wraps the stages of an incoming RPC that are handled by a
+// dispatcher and the invoker it returns.  Each non-nil stage function must
+// either invoke next to continue the processing of the RPC or return without
+// invoking it to short-circuit the RPC.  Nil stage functions don't intercept
+// the corresponding stage.
+type Interceptor struct {
+	Lookup  func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error)
+	Prepare func(ctx *context.T, method string, numArgs int, next PrepareFunc) ([]interface{}, []*vdl.Value, error)
+	Invoke  func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error)
+}
+
+// Intercept returns a dispatcher that runs each lookup on the provided
+// dispatcher, and each Prepare and Invoke on the invokers it returns, through
+// the provided interceptors.  The first interceptor is the outermost one.
+func Intercept(d rpc.Dispatcher, chain ...Interceptor) rpc.Dispatcher {
+	if len(chain) == 0 {
+		return d
+	}
+	id := &interceptedDispatcher{chain: chain}
+	id.lookup = d.Lookup
+	for i := len(chain) - 1; i >= 0; i-- {
+		if ic, next := chain[i].Lookup, id.lookup; ic != nil {
+			id.lookup = func(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+				return ic(ctx, suffix, next)
+			}
+		}
+	}
+	return id
+}
+
+type interceptedDispatcher struct {
+	chain  []Interceptor
+	lookup LookupFunc
+}
+
+func (d *interceptedDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	obj, auth, err := d.lookup(ctx, suffix)
+	if err != nil || obj == nil {
+		return obj, auth, err
+	}
+	inv, ok := obj.(rpc.Invoker)
+	if !ok {
+		if inv, err = rpc.ReflectInvoker(obj); err != nil {
+			return nil, nil, err
+		}
+	}
+	ii := &interceptedInvoker{Invoker: inv, prepare: inv.Prepare, invoke: inv.Invoke}
+	for i := len(d.chain) - 1; i >= 0; i-- {
+		if ic, next := d.chain[i].Prepare, ii.prepare; ic != nil {
+			ii.prepare = func(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error) {
+				return ic(ctx, method, numArgs, next)
+			}
+		}
+		if ic, next := d.chain[i].Invoke, ii.invoke; ic != nil {
+			ii.invoke = func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) ([]interface{}, error) {
+				return ic(ctx, call, method, argptrs, next)
+			}
+		}
+	}
+	return ii, auth, nil
+}
+
+// interceptedInvoker runs Prepare and Invoke through an interceptor chain and
+// forwards all other calls to the wrapped invoker.
+type interceptedInvoker struct {
+	rpc.Invoker
+	prepare PrepareFunc
+	invoke  InvokeFunc
+}
+
+func (i *interceptedInvoker) Prepare(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error) {
+	return i.prepare(ctx, method, numArgs)
+}
+
+func (i *interceptedInvoker) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) ([]interface{}, error) {
+	return i.invoke(ctx, call, method, argptrs)
+}
+
+// RecoverInterceptor converts panics in the later stages of the chain into
+// errors returned to the client.
+func RecoverInterceptor() Interceptor {
+	recovered := func(ctx *context.T, stage string, err *error) {
+		if r := recover(); r != nil {
+			ctx.Errorf("panic during %s: %v", stage, r)
+			*err = verror.New(verror.ErrInternal, ctx, fmt.Sprintf("panic during %s: %v", stage, r))
+		}
+	}
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (obj interface{}, auth security.Authorizer, err error) {
+			defer recovered(ctx, "lookup", &err)
+			return next(ctx, suffix)
+		},
+		Prepare: func(ctx *context.T, method string, numArgs int, next PrepareFunc) (argptrs []interface{}, tags []*vdl.Value, err error) {
+			defer recovered(ctx, "prepare", &err)
+			return next(ctx, method, numArgs)
+		},
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) (results []interface{}, err error) {
+			defer recovered(ctx, "invoke", &err)
+			return next(ctx, call, method, argptrs)
+		},
+	}
+}
+
+// LoggingInterceptor logs every lookup and invocation, along with its
+// duration and outcome.
+func LoggingInterceptor() Interceptor {
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error) {
+			start := time.Now()
+			obj, auth, err := next(ctx, suffix)
+			ctx.Infof("Lookup(%q) took %v, found: %t, error: %v", suffix, time.Since(start), obj != nil, err)
+			return obj, auth, err
+		},
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error) {
+			start := time.Now()
+			results, err := next(ctx, call, method, argptrs)
+			ctx.Infof("Invoke(%q, %q) from %v took %v, error: %v", call.Suffix(), method, call.RemoteEndpoint(), time.Since(start), err)
+			return results, err
+		},
+	}
+}
+
+// MetricsInterceptor exports, for every invoked method, the number of calls,
+// the number of failed calls and the total latency (in microseconds) of the
+// calls as stats counters named <prefix>/<method>/{calls,errors,latency-us}.
+func MetricsInterceptor(prefix string) Interceptor {
+	m := &methodMetrics{prefix: prefix, counters: make(map[string]*methodCounters)}
+	return Interceptor{
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error) {
+			start := time.Now()
+			results, err := next(ctx, call, method, argptrs)
+			m.record(method, time.Since(start), err)
+			return results, err
+		},
+	}
+}
+
+type methodMetrics struct {
+	prefix   string
+	mu       sync.Mutex
+	counters map[string]*methodCounters
+}
+
+type methodCounters struct {
+	calls, errors, latency *counter.Counter
+}
+
+func (m *methodMetrics) get(method string) *methodCounters {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[method]
+	if !ok {
+		name := naming.Join(m.prefix, method)
+		c = &methodCounters{
+			calls:   stats.NewCounter(naming.Join(name, "calls")),
+			errors:  stats.NewCounter(naming.Join(name, "errors")),
+			latency: stats.NewCounter(naming.Join(name, "latency-us")),
+		}
+		m.counters[method] = c
+	}
+	return c
+}
+
+func (m *methodMetrics) record(method string, latency time.Duration, err error) {
+	c := m.get(method)
+	c.calls.Incr(1)
+	if err != nil {
+		c.errors.Incr(1)
+	}
+	c.latency.Incr(int64(latency / time.Microsecond))
+}
+
+// RateLimitInterceptor rejects requests, at lookup time, that exceed the
+// provided rate (in requests per second), allowing bursts of up to the
+// provided size.  Rejected requests fail with a RetryBackoff error.
+func RateLimitInterceptor(rate float64, burst int) Interceptor {
+	b := newTokenBucket(rate, burst, time.Now)
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error) {
+			if !b.take() {
+				return nil, nil, verror.New(errRateLimited, ctx)
+			}
+			return next(ctx, suffix)
+		},
+	}
+}
+
+// tokenBucket implements the token bucket rate-limiting algorithm.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		now:    now,
+		tokens: float64(burst),
+		last:   now(),
+	}
+}
+
+// take removes a token from the bucket, returning false if the bucket is
+// empty.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// AuditInterceptor logs every authorization decision made for the objects
+// found by the dispatcher.  Objects without an authorizer are authorized by
+// security.DefaultAuthorizer(), as they would be without the interceptor.
+func AuditInterceptor() Interceptor {
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error) {
+			obj, auth, err := next(ctx, suffix)
+			if err != nil || obj == nil {
+				return obj, auth, err
+			}
+			if auth == nil {
+				auth = security.DefaultAuthorizer()
+			}
+			return obj, auditAuthorizer{auth}, nil
+		},
+	}
+}
+
+type auditAuthorizer struct {
+	security.Authorizer
+}
+
+func (a auditAuthorizer) Authorize(ctx *context.T, call security.Call) error {
+	err := a.Authorizer.Authorize(ctx, call)
+	names, rejected := security.RemoteBlessingNames(ctx, call)
+	if err != nil {
+		ctx.Infof("Audit: denied %v (rejected %v) access to %q.%s: %v", names, rejected, call.Suffix(), call.Method(), err)
+	} else {
+		ctx.Infof("Audit: granted %v access to %q.%s", names, call.Suffix(), call.Method())
+	}
+	return err
+}
+
+// ServerInterceptors returns the interceptor chain requested by the provided
+// server options, ordered from the outermost interceptor to the innermost
+// one: panic recovery, rate limiting, request logging, method metrics,
+// authorization auditing and admission control.
+func ServerInterceptors(opts jopts.ServerInterceptorOpts) []Interceptor {
+	var chain []Interceptor
+	if opts.RecoverPanics {
+		chain = append(chain, RecoverInterceptor())
+	}
+	if opts.RateLimit > 0 {
+		chain = append(chain, RateLimitInterceptor(opts.RateLimit, opts.RateLimitBurst))
+	}
+	if opts.LogCalls {
+		chain = append(chain, LoggingInterceptor())
+	}
+	if opts.MetricsPrefix != "" {
+		chain = append(chain, MetricsInterceptor(opts.MetricsPrefix))
+	}
+	if opts.AuditAuthorization {
+		chain = append(chain, AuditInterceptor())
+	}
+	if limits := admissionLimits(opts); limits.MaxConcurrentCalls > 0 || limits.MaxConcurrentCallsPerMethod > 0 || len(limits.MethodLimits) > 0 {
+		chain = append(chain, AdmissionInterceptor(limits))
+	}
+	return chain
+}
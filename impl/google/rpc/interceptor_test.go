@@ -0,0 +1,161 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+
+	jopts "v.io/x/jni/v23/options"
+)
+
+// fakeInvoker is an rpc.Invoker whose Prepare and Invoke record their
+// invocation in a trace.
+type fakeInvoker struct {
+	rpc.Invoker
+	trace *[]string
+}
+
+func (i fakeInvoker) Prepare(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error) {
+	*i.trace = append(*i.trace, "prepare "+method)
+	return nil, nil, nil
+}
+
+func (i fakeInvoker) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) ([]interface{}, error) {
+	*i.trace = append(*i.trace, "invoke "+method)
+	return nil, nil
+}
+
+type fakeDispatcher struct {
+	trace *[]string
+}
+
+func (d fakeDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	*d.trace = append(*d.trace, "lookup "+suffix)
+	return fakeInvoker{trace: d.trace}, nil, nil
+}
+
+// tracingInterceptor records entering and leaving every stage.
+func tracingInterceptor(name string, trace *[]string) Interceptor {
+	return Interceptor{
+		Lookup: func(ctx *context.T, suffix string, next LookupFunc) (interface{}, security.Authorizer, error) {
+			*trace = append(*trace, name+" lookup")
+			defer func() { *trace = append(*trace, name+" lookup done") }()
+			return next(ctx, suffix)
+		},
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error) {
+			*trace = append(*trace, name+" invoke")
+			return next(ctx, call, method, argptrs)
+		},
+	}
+}
+
+func TestInterceptOrder(t *testing.T) {
+	var trace []string
+	d := Intercept(fakeDispatcher{&trace}, tracingInterceptor("outer", &trace), tracingInterceptor("inner", &trace))
+	obj, _, err := d.Lookup(nil, "suffix")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	inv := obj.(rpc.Invoker)
+	inv.Prepare(nil, "Method", 0)
+	inv.Invoke(nil, nil, "Method", nil)
+	want := []string{
+		"outer lookup", "inner lookup", "lookup suffix", "inner lookup done", "outer lookup done",
+		"prepare Method",
+		"outer invoke", "inner invoke", "invoke Method",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace %v, want %v", trace, want)
+	}
+}
+
+func TestInterceptShortCircuit(t *testing.T) {
+	var trace []string
+	errDenied := errors.New("denied")
+	deny := Interceptor{
+		Invoke: func(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}, next InvokeFunc) ([]interface{}, error) {
+			return nil, errDenied
+		},
+	}
+	obj, _, _ := Intercept(fakeDispatcher{&trace}, deny).Lookup(nil, "")
+	if _, err := obj.(rpc.Invoker).Invoke(nil, nil, "Method", nil); err != errDenied {
+		t.Errorf("got error %v, want %v", err, errDenied)
+	}
+	if want := []string{"lookup "}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("got trace %v, want %v", trace, want)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2, 3, func() time.Time { return now })
+	for i := 0; i < 3; i++ {
+		if !b.take() {
+			t.Fatalf("take %d within the burst failed", i)
+		}
+	}
+	if b.take() {
+		t.Errorf("take beyond the burst succeeded")
+	}
+	now = now.Add(500 * time.Millisecond)
+	if !b.take() {
+		t.Errorf("take after refill failed")
+	}
+	if b.take() {
+		t.Errorf("take beyond the refill succeeded")
+	}
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !b.take() {
+			t.Fatalf("take %d after a long pause failed", i)
+		}
+	}
+	if b.take() {
+		t.Errorf("bucket refilled beyond the burst")
+	}
+}
+
+func TestServerInterceptors(t *testing.T) {
+	if chain := ServerInterceptors(jopts.ServerInterceptorOpts{}); len(chain) != 0 {
+		t.Errorf("got %d interceptors for unset options, want none", len(chain))
+	}
+	opts := jopts.ServerInterceptorOpts{
+		RecoverPanics:      true,
+		LogCalls:           true,
+		AuditAuthorization: true,
+		MaxConcurrentCalls: 1,
+	}
+	if chain := ServerInterceptors(opts); len(chain) != 4 {
+		t.Errorf("got %d interceptors, want 4", len(chain))
+	}
+
+	opts = jopts.ServerInterceptorOpts{
+		MetricsPrefix:               "server",
+		MaxConcurrentCalls:          8,
+		MaxConcurrentCallsPerMethod: 2,
+		MethodConcurrencyLimits:     map[string]int{"Get": 4},
+		MaxQueuedCalls:              16,
+		MaxQueueWait:                time.Second,
+	}
+	want := AdmissionLimits{
+		MaxConcurrentCalls:          8,
+		MaxConcurrentCallsPerMethod: 2,
+		MethodLimits:                map[string]int{"Get": 4},
+		MaxQueuedCalls:              16,
+		MaxQueueWait:                time.Second,
+		StatsPrefix:                 "server/admission",
+	}
+	if got := admissionLimits(opts); !reflect.DeepEqual(got, want) {
+		t.Errorf("got admission limits %+v, want %+v", got, want)
+	}
+}
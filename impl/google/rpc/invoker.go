@@ -9,6 +9,7 @@ package rpc
 import (
 	"fmt"
 	"runtime"
+	"sync"
 
 	"v.io/v23/context"
 	"v.io/v23/glob"
@@ -44,44 +45,67 @@ func goInvoker(env jutil.Env, jInvoker jutil.Object) (rpc.Invoker, error) {
 
 type invoker struct {
 	jInvoker jutil.Object
+	methods  *methodCache // nil if method tags and signatures aren't memoized
+
+	globberOnce     sync.Once
+	childrenGlobber bool
 }
 
 func (i *invoker) Prepare(ctx *context.T, method string, numArgs int) (argptrs []interface{}, tags []*vdl.Value, err error) {
-	env, freeFunc := jutil.GetEnv()
-	jContext, err := jcontext.JavaContext(env, ctx, nil)
-	if err != nil {
-		freeFunc()
-		return nil, nil, err
-	}
 	// Have all input arguments be decoded into *vdl.Value.
 	argptrs = make([]interface{}, numArgs)
 	for i := 0; i < numArgs; i++ {
 		value := new(vdl.Value)
 		argptrs[i] = &value
 	}
+	if i.methods != nil {
+		tags, err = i.methods.getTags(method, func() ([]*vdl.Value, error) {
+			return i.prepare(ctx, method)
+		})
+	} else {
+		tags, err = i.prepare(ctx, method)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return
+}
+
+// prepare fetches the tags of the provided method from the Java invoker.
+func (i *invoker) prepare(ctx *context.T, method string) ([]*vdl.Value, error) {
+	env, freeFunc := jutil.GetEnv()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		freeFunc()
+		return nil, err
+	}
 	// This method will invoke the freeFunc().
 	jVomTags, err := jutil.CallStaticFutureMethod(env, freeFunc, jServerRPCHelperClass, "prepare", []jutil.Sign{invokerSign, contextSign, jutil.StringSign}, i.jInvoker, jContext, jutil.CamelCase(method))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	env, freeFunc = jutil.GetEnv()
 	defer freeFunc()
 	defer jutil.DeleteGlobalRef(env, jVomTags)
 	vomTags, err := jutil.GoByteArrayArray(env, jVomTags)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	tags = make([]*vdl.Value, len(vomTags))
+	tags := make([]*vdl.Value, len(vomTags))
 	for i, vomTag := range vomTags {
 		var err error
 		if tags[i], err = jutil.VomDecodeToValue(vomTag); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 	}
-	return
+	return tags, nil
 }
 
 func (i *invoker) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) (results []interface{}, err error) {
+	call, rec := recordServerCall(ctx, call, method, argptrs)
+	defer func() { rec.finish(results, err) }()
+	// Stop receiving stream items in batches once the call has returned.
+	defer stopRecvBatcher(call)
 	env, freeFunc := jutil.GetEnv()
 	jContext, err := jcontext.JavaContext(env, ctx, nil)
 	if err != nil {
@@ -125,6 +149,16 @@ func (i *invoker) Invoke(ctx *context.T, call rpc.StreamServerCall, method strin
 }
 
 func (i *invoker) Signature(ctx *context.T, call rpc.ServerCall) ([]signature.Interface, error) {
+	if i.methods != nil {
+		return i.methods.getSignature(func() ([]signature.Interface, error) {
+			return i.signature(ctx)
+		})
+	}
+	return i.signature(ctx)
+}
+
+// signature fetches the signature of the Java invoker.
+func (i *invoker) signature(ctx *context.T) ([]signature.Interface, error) {
 	env, freeFunc := jutil.GetEnv()
 	jContext, err := jcontext.JavaContext(env, ctx, nil)
 	if err != nil {
@@ -154,6 +188,17 @@ func (i *invoker) Signature(ctx *context.T, call rpc.ServerCall) ([]signature.In
 }
 
 func (i *invoker) MethodSignature(ctx *context.T, call rpc.ServerCall, method string) (signature.Method, error) {
+	if i.methods != nil {
+		return i.methods.getMethodSignature(method, func() (signature.Method, error) {
+			return i.methodSignature(ctx, method)
+		})
+	}
+	return i.methodSignature(ctx, method)
+}
+
+// methodSignature fetches the signature of the provided method from the Java
+// invoker.
+func (i *invoker) methodSignature(ctx *context.T, method string) (signature.Method, error) {
 	env, freeFunc := jutil.GetEnv()
 	jContext, err := jcontext.JavaContext(env, ctx, nil)
 	if err != nil {
@@ -177,15 +222,83 @@ func (i *invoker) MethodSignature(ctx *context.T, call rpc.ServerCall, method st
 }
 
 func (i *invoker) Globber() *rpc.GlobState {
+	if i.isChildrenGlobber() {
+		return &rpc.GlobState{ChildrenGlobber: javaChildrenGlobber{i}}
+	}
 	return &rpc.GlobState{AllGlobber: javaGlobber{i}}
 }
 
+// isChildrenGlobber returns true iff the Java invoker enumerates the
+// immediate children of its object rather than matching glob patterns
+// itself.
+func (i *invoker) isChildrenGlobber() bool {
+	i.globberOnce.Do(func() {
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		var err error
+		if i.childrenGlobber, err = jutil.CallStaticBooleanMethod(env, jServerRPCHelperClass, "isChildrenGlobber", []jutil.Sign{invokerSign}, i.jInvoker); err != nil {
+			// Fall back to the all-globber, which every Java invoker supports.
+			i.childrenGlobber = false
+		}
+	})
+	return i.childrenGlobber
+}
+
+// javaChildrenGlobber implements rpc.ChildrenGlobber for Java invokers: Java
+// enumerates the names of the immediate children, while pattern matching,
+// recursion and error reporting are left to the Go glob implementation.
+type javaChildrenGlobber struct {
+	i *invoker
+}
+
+func (j javaChildrenGlobber) GlobChildren__(ctx *context.T, call rpc.GlobChildrenServerCall, m *glob.Element) error {
+	env, freeFunc := jutil.GetEnv()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		freeFunc()
+		return err
+	}
+	jServerCall, err := JavaServerCall(env, call)
+	if err != nil {
+		freeFunc()
+		return err
+	}
+	convert := func(input jutil.Object) (interface{}, error) {
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		return jutil.GoString(env, input), nil
+	}
+	send := func(item interface{}) error {
+		child, ok := item.(string)
+		if !ok {
+			return fmt.Errorf("Expected item of type string, got: %T", item)
+		}
+		if !m.Match(child) {
+			return nil
+		}
+		return call.SendStream().Send(naming.GlobChildrenReplyName{Value: child})
+	}
+	close := func() error {
+		return nil
+	}
+	jOutputChannel, err := jchannel.JavaOutputChannel(env, ctx, nil, convert, send, close)
+	if err != nil {
+		freeFunc()
+		return err
+	}
+	channelSign := jutil.ClassSign("io.v.v23.OutputChannel")
+	// This method will invoke the freeFunc().
+	_, err = jutil.CallStaticFutureMethod(env, freeFunc, jServerRPCHelperClass, "globChildren", []jutil.Sign{invokerSign, contextSign, serverCallSign, channelSign}, j.i.jInvoker, jContext, jServerCall, jOutputChannel)
+	return err
+}
+
+// javaGlobber implements rpc.AllGlobber for Java invokers that match glob
+// patterns themselves.
 type javaGlobber struct {
 	i *invoker
 }
 
 func (j javaGlobber) Glob__(ctx *context.T, call rpc.GlobServerCall, g *glob.Glob) error {
-	// TODO(sjr,rthellend): Update the Java API to match the new GO API.
 	env, freeFunc := jutil.GetEnv()
 	jContext, err := jcontext.JavaContext(env, ctx, nil)
 	if err != nil {
@@ -7,17 +7,22 @@
 package rpc
 
 import (
+	"fmt"
 	"io"
+	"time"
 	"unsafe"
 
 	"v.io/v23/context"
 	"v.io/v23/rpc"
 	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
 	"v.io/v23/verror"
 	"v.io/v23/vom"
 	"v.io/x/lib/vlog"
 
+	jchannel "v.io/x/jni/impl/google/channel"
 	jble "v.io/x/jni/impl/google/rpc/protocols/ble"
+	jbridge "v.io/x/jni/impl/google/rpc/protocols/bridge"
 	jbt "v.io/x/jni/impl/google/rpc/protocols/bt"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
@@ -74,6 +79,8 @@ var (
 	jServerStatusClass jutil.Class
 	// Global reference for io.v.v23.rpc.ServerState class.
 	jServerStateClass jutil.Class
+	// Global reference for io.v.v23.rpc.ServerStatusChange class.
+	jServerStatusChangeClass jutil.Class
 	// Global reference for io.v.v23.OptionDefs class.
 	jOptionDefsClass jutil.Class
 	// Global reference for io.v.v23.naming.Endpoint.
@@ -91,6 +98,9 @@ var (
 // Init initializes the JNI code with the given Java environment. This method
 // must be called from the main Java thread.
 func Init(env jutil.Env) error {
+	if err := jbridge.Init(env); err != nil {
+		return err
+	}
 	if err := jbt.Init(env); err != nil {
 		return err
 	}
@@ -171,6 +181,10 @@ func Init(env jutil.Env) error {
 	if err != nil {
 		return err
 	}
+	jServerStatusChangeClass, err = jutil.JFindClass(env, "io/v/v23/rpc/ServerStatusChange")
+	if err != nil {
+		return err
+	}
 	jOptionDefsClass, err = jutil.JFindClass(env, "io/v/v23/OptionDefs")
 	if err != nil {
 		return err
@@ -227,6 +241,41 @@ func Java_io_v_impl_google_rpc_ServerImpl_nativeGetStatus(jenv *C.JNIEnv, jServe
 	return C.jobject(unsafe.Pointer(jStatus))
 }
 
+// serverHealth returns the health attached to the provided server, or an
+// error if the server doesn't serve a health service.
+func serverHealth(server rpc.Server) (*Health, error) {
+	h := ServerHealth(server)
+	if h == nil {
+		return nil, fmt.Errorf("server doesn't serve a health service")
+	}
+	return h, nil
+}
+
+//export Java_io_v_impl_google_rpc_ServerImpl_nativeSetReady
+func Java_io_v_impl_google_rpc_ServerImpl_nativeSetReady(jenv *C.JNIEnv, jServer C.jobject, goRef C.jlong, jReady C.jboolean) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	h, err := serverHealth(*(*rpc.Server)(jutil.GoRefValue(jutil.Ref(goRef))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return
+	}
+	h.SetReady(jReady == C.JNI_TRUE)
+}
+
+//export Java_io_v_impl_google_rpc_ServerImpl_nativeIsReady
+func Java_io_v_impl_google_rpc_ServerImpl_nativeIsReady(jenv *C.JNIEnv, jServer C.jobject, goRef C.jlong) C.jboolean {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	h, err := serverHealth(*(*rpc.Server)(jutil.GoRefValue(jutil.Ref(goRef))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return C.JNI_FALSE
+	}
+	if h.Status().Ready {
+		return C.JNI_TRUE
+	}
+	return C.JNI_FALSE
+}
+
 //export Java_io_v_impl_google_rpc_ServerImpl_nativeAllPublished
 func Java_io_v_impl_google_rpc_ServerImpl_nativeAllPublished(jenv *C.JNIEnv, jServer C.jobject, goRef C.jlong, jContext C.jobject, jCallbackObj C.jobject) {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
@@ -251,6 +300,55 @@ func Java_io_v_impl_google_rpc_ServerImpl_nativeAllPublished(jenv *C.JNIEnv, jSe
 	})
 }
 
+//export Java_io_v_impl_google_rpc_ServerImpl_nativeWatchStatus
+func Java_io_v_impl_google_rpc_ServerImpl_nativeWatchStatus(jenv *C.JNIEnv, jServer C.jobject, goRef C.jlong, jContext C.jobject) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	server := *(*rpc.Server)(jutil.GoRefValue(jutil.Ref(goRef)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// The first change describes the entire status, i.e., it is computed
+	// against an empty status.
+	var last rpc.ServerStatus
+	var dirty <-chan struct{}
+	stopped := false
+	// Changes are converted to Java only once they are received by Java, so that they can be
+	// filtered by the channel combinators first.
+	jChannel, err := jchannel.JavaValueInputChannel(env, ctx, cancel, func() (interface{}, error) {
+		for {
+			if stopped {
+				return nil, verror.NewErrEndOfFile(ctx)
+			}
+			if dirty != nil {
+				select {
+				case <-dirty:
+				case <-ctx.Done():
+					return nil, verror.NewErrEndOfFile(ctx)
+				}
+			}
+			status := server.Status()
+			dirty = status.Dirty
+			change := diffServerStatus(last, status)
+			last = status
+			stopped = status.State == rpc.ServerStopped
+			if change.empty() && !stopped {
+				continue
+			}
+			return change.value()
+		}
+	}, func(env jutil.Env, value interface{}) (jutil.Object, error) {
+		return javaServerStatusChange(env, value.(statusChangeValue).change)
+	})
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jChannel))
+}
+
 //export Java_io_v_impl_google_rpc_ServerImpl_nativeFinalize
 func Java_io_v_impl_google_rpc_ServerImpl_nativeFinalize(jenv *C.JNIEnv, jServer C.jobject, goRef C.jlong) {
 	jutil.GoDecRef(jutil.Ref(goRef))
@@ -272,20 +370,50 @@ func decodeArgs(env jutil.Env, jVomArgs C.jobjectArray) ([]interface{}, error) {
 	return args, nil
 }
 
-func doStartCall(context *context.T, cancel func(), name, method string, opts []rpc.CallOpt, goRef C.jlong, args []interface{}) (jutil.Object, error) {
+// startCallWithRetries starts the call, retrying it according to the
+// RetryPolicy in the provided options (if any).
+func startCallWithRetries(ctx *context.T, client rpc.Client, name, method string, args []interface{}, opts []rpc.CallOpt) (rpc.ClientCall, error) {
+	policy, opts := jopts.SplitRetryPolicy(opts)
+	if policy == nil {
+		return client.StartCall(ctx, name, method, args, opts...)
+	}
+	for attempt := 1; ; attempt++ {
+		call, err := client.StartCall(ctx, name, method, args, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.Retryable(err) {
+			return call, err
+		}
+		backoff := policy.Backoff(attempt)
+		ctx.VI(1).Infof("StartCall(%q, %q) attempt %d failed, retrying in %v: %v", name, method, attempt, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+func doStartCall(ctx *context.T, cancel func(), name, method string, opts []rpc.CallOpt, goRef C.jlong, args []interface{}) (jutil.Object, error) {
+	// Run the call with its own context, so that it can be canceled
+	// independently of the context it was started with.
+	callCtx, callCancel := context.WithCancel(ctx)
 	// Invoke StartCall
-	call, err := (*(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef)))).StartCall(context, name, method, args, opts...)
+	start := time.Now()
+	call, err := startCallWithRetries(callCtx, *(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef))), name, method, args, opts)
 	if err != nil {
+		callCancel()
 		return jutil.NullObject, err
 	}
+	cc := newClientCall(callCtx, callCancel, recordClientCall(callCtx, call, start, name, method, args))
 	env, freeFunc := jutil.GetEnv()
 	defer freeFunc()
-	jContext, err := jcontext.JavaContext(env, context, cancel)
+	jContext, err := jcontext.JavaContext(env, ctx, cancel)
 	if err != nil {
+		cc.Close()
 		return jutil.NullObject, err
 	}
-	jCall, err := javaCall(env, jContext, call)
+	jCall, err := javaCall(env, jContext, cc)
 	if err != nil {
+		cc.Close()
 		return jutil.NullObject, err
 	}
 	// Must grab a global reference as we free up the env and all local references that come along
@@ -318,13 +446,79 @@ func Java_io_v_impl_google_rpc_ClientImpl_nativeStartCall(jenv *C.JNIEnv, jClien
 		return
 	}
 
-	// Create a global reference to the client object for the duration of the call
+	// Keep a global reference to the client object for the duration of the call
 	// so that the java object doesn't get garbage collected while the async call
 	// is happening. This is an issue because if the java object is garbage collected,
 	// the go ref will also be collected causing doStartCall to panic.
+	doAsyncClientCall(env, jClientObj, jCallback, func() (jutil.Object, error) {
+		return doStartCall(ctx, cancel, name, method, opts, goRef, args)
+	})
+}
+
+// remoteSignature fetches the signature of the provided remote object.
+func remoteSignature(ctx *context.T, client rpc.Client, name string, opts []rpc.CallOpt) ([]signature.Interface, error) {
+	call, err := startCallWithRetries(ctx, client, name, rpc.ReservedSignature, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	var sig []signature.Interface
+	if err := call.Finish(&sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// doDynamicCall invokes the provided method, whose arguments are obtained by
+// passing the method's signature (fetched from the remote object) to the
+// provided args function.  The results are returned as a Java VdlValue array.
+func doDynamicCall(ctx *context.T, client rpc.Client, name, method string, opts []rpc.CallOpt, args func(signature.Method) ([]interface{}, error)) (jutil.Object, error) {
+	sig, err := remoteSignature(ctx, client, name, opts)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	m, err := findMethod(sig, method)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	goArgs, err := args(m)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	start := time.Now()
+	call, err := startCallWithRetries(ctx, client, name, method, goArgs, opts)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	call = recordClientCall(ctx, call, start, name, method, goArgs)
+	results := make([]*vdl.Value, len(m.OutArgs))
+	resultPtrs := make([]interface{}, len(results))
+	for i := range results {
+		resultPtrs[i] = &results[i]
+	}
+	if err := call.Finish(resultPtrs...); err != nil {
+		return jutil.NullObject, err
+	}
+	if results, err = dynamicResults(m, results); err != nil {
+		return jutil.NullObject, err
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jResults, err := jutil.JVDLValueArray(env, results)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	// Must grab a global reference as we free up the env and all local references that come along
+	// with it.
+	return jutil.NewGlobalRef(env, jResults), nil // Un-refed in DoAsyncCall
+}
+
+// doAsyncClientCall invokes f asynchronously, keeping the provided Java client
+// object from being garbage-collected (and its Go client freed) until f
+// returns.
+func doAsyncClientCall(env jutil.Env, jClientObj C.jobject, jCallback jutil.Object, f func() (jutil.Object, error)) {
 	jClient := jutil.NewGlobalRef(env, jutil.Object(uintptr(unsafe.Pointer(jClientObj))))
 	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
-		obj, err := doStartCall(ctx, cancel, name, method, opts, goRef, args)
+		obj, err := f()
 		env, freeFunc := jutil.GetEnv()
 		jutil.DeleteGlobalRef(env, jClient)
 		freeFunc()
@@ -332,6 +526,104 @@ func Java_io_v_impl_google_rpc_ClientImpl_nativeStartCall(jenv *C.JNIEnv, jClien
 	})
 }
 
+// dynamicCallArgs returns the Go arguments of the dynamic call methods of
+// ClientImpl.
+func dynamicCallArgs(env jutil.Env, jContext C.jobject, jName, jMethod C.jstring, jOptionsObj C.jobject) (ctx *context.T, name, method string, opts []rpc.CallOpt, err error) {
+	if ctx, _, err = jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext)))); err != nil {
+		return
+	}
+	name = jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	method = jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jMethod))))
+	opts, err = jopts.GoRpcOpts(env, jutil.Object(uintptr(unsafe.Pointer(jOptionsObj))))
+	return
+}
+
+//export Java_io_v_impl_google_rpc_ClientImpl_nativeDynamicCall
+func Java_io_v_impl_google_rpc_ClientImpl_nativeDynamicCall(jenv *C.JNIEnv, jClientObj C.jobject, goRef C.jlong,
+	jContext C.jobject, jName C.jstring, jMethod C.jstring, jArgs C.jobjectArray, jOptionsObj C.jobject, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	ctx, name, method, opts, err := dynamicCallArgs(env, jContext, jName, jMethod, jOptionsObj)
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	args, err := jutil.GoVDLValueArray(env, jutil.Object(uintptr(unsafe.Pointer(jArgs))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	client := *(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef)))
+	doAsyncClientCall(env, jClientObj, jCallback, func() (jutil.Object, error) {
+		return doDynamicCall(ctx, client, name, method, opts, func(m signature.Method) ([]interface{}, error) {
+			return dynamicArgs(m, args)
+		})
+	})
+}
+
+//export Java_io_v_impl_google_rpc_ClientImpl_nativeDynamicCallJson
+func Java_io_v_impl_google_rpc_ClientImpl_nativeDynamicCallJson(jenv *C.JNIEnv, jClientObj C.jobject, goRef C.jlong,
+	jContext C.jobject, jName C.jstring, jMethod C.jstring, jJsonArgs C.jobjectArray, jOptionsObj C.jobject, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	ctx, name, method, opts, err := dynamicCallArgs(env, jContext, jName, jMethod, jOptionsObj)
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	jsonArgs, err := jutil.GoStringArray(env, jutil.Object(uintptr(unsafe.Pointer(jJsonArgs))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	client := *(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef)))
+	doAsyncClientCall(env, jClientObj, jCallback, func() (jutil.Object, error) {
+		return doDynamicCall(ctx, client, name, method, opts, func(m signature.Method) ([]interface{}, error) {
+			return dynamicJSONArgs(m, jsonArgs)
+		})
+	})
+}
+
+//export Java_io_v_impl_google_rpc_ClientImpl_nativeGetSignature
+func Java_io_v_impl_google_rpc_ClientImpl_nativeGetSignature(jenv *C.JNIEnv, jClientObj C.jobject, goRef C.jlong,
+	jContext C.jobject, jName C.jstring, jOptionsObj C.jobject, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	opts, err := jopts.GoRpcOpts(env, jutil.Object(uintptr(unsafe.Pointer(jOptionsObj))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	client := *(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef)))
+	doAsyncClientCall(env, jClientObj, jCallback, func() (jutil.Object, error) {
+		sig, err := remoteSignature(ctx, client, name, opts)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		jInterfaces := make([]jutil.Object, len(sig))
+		for i, iface := range sig {
+			if jInterfaces[i], err = jutil.JVomCopy(env, iface, jInterfaceClass); err != nil {
+				return jutil.NullObject, err
+			}
+		}
+		jArr, err := jutil.JObjectArray(env, jInterfaces, jInterfaceClass)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		// Must grab a global reference as we free up the env and all local references that come
+		// along with it.
+		return jutil.NewGlobalRef(env, jArr), nil // Un-refed in DoAsyncCall
+	})
+}
+
 //export Java_io_v_impl_google_rpc_ClientImpl_nativeClose
 func Java_io_v_impl_google_rpc_ClientImpl_nativeClose(jenv *C.JNIEnv, jClient C.jobject, goRef C.jlong) {
 	(*(*rpc.Client)(jutil.GoRefValue(jutil.Ref(goRef)))).Close()
@@ -356,20 +648,62 @@ func Java_io_v_impl_google_rpc_StreamImpl_nativeSend(jenv *C.JNIEnv, jStream C.j
 	})
 }
 
+//export Java_io_v_impl_google_rpc_StreamImpl_nativeSendBatch
+func Java_io_v_impl_google_rpc_StreamImpl_nativeSendBatch(jenv *C.JNIEnv, jStream C.jobject, goRef C.jlong, jVomItems C.jobjectArray, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	vomItems, err := jutil.GoByteArrayArray(env, jutil.Object(uintptr(unsafe.Pointer(jVomItems))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
+		stream := *(*rpc.Stream)(jutil.GoRefValue(jutil.Ref(goRef)))
+		for i, vomItem := range vomItems {
+			item, err := jutil.VomDecodeToValue(vomItem)
+			if err != nil {
+				return jutil.NullObject, err
+			}
+			if err := stream.Send(item); err != nil {
+				return jutil.NullObject, fmt.Errorf("couldn't send item %d of %d: %v", i+1, len(vomItems), err)
+			}
+		}
+		return jutil.NullObject, nil
+	})
+}
+
+// recvVom receives a single item from the provided stream and VOM-encodes it.
+func recvVom(stream rpc.Stream) ([]byte, error) {
+	result := new(vdl.Value)
+	if err := stream.Recv(&result); err != nil {
+		if err == io.EOF {
+			// Java uses EndOfFile error to detect EOF.
+			err = verror.NewErrEndOfFile(nil)
+		}
+		return nil, err
+	}
+	return vom.Encode(result)
+}
+
 //export Java_io_v_impl_google_rpc_StreamImpl_nativeRecv
 func Java_io_v_impl_google_rpc_StreamImpl_nativeRecv(jenv *C.JNIEnv, jStream C.jobject, goRef C.jlong, jCallbackObj C.jobject) {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
-	result := new(vdl.Value)
+	stream := *(*rpc.Stream)(jutil.GoRefValue(jutil.Ref(goRef)))
+	// Once batched receives are used, single-item receives go through the
+	// stream's batcher too, so that the two never race.
+	b := getRecvBatcher(stream)
 	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
-		if err := (*(*rpc.Stream)(jutil.GoRefValue(jutil.Ref(goRef)))).Recv(&result); err != nil {
-			if err == io.EOF {
-				// Java uses EndOfFile error to detect EOF.
-				err = verror.NewErrEndOfFile(nil)
+		var vomResult []byte
+		var err error
+		if b != nil {
+			var batch [][]byte
+			if batch, err = b.next(1, 0, 0); err == nil {
+				vomResult = batch[0]
 			}
-			return jutil.NullObject, err
+		} else {
+			vomResult, err = recvVom(stream)
 		}
-		vomResult, err := vom.Encode(result)
 		if err != nil {
 			return jutil.NullObject, err
 		}
@@ -385,6 +719,36 @@ func Java_io_v_impl_google_rpc_StreamImpl_nativeRecv(jenv *C.JNIEnv, jStream C.j
 	})
 }
 
+//export Java_io_v_impl_google_rpc_StreamImpl_nativeRecvBatch
+func Java_io_v_impl_google_rpc_StreamImpl_nativeRecvBatch(jenv *C.JNIEnv, jStream C.jobject, goRef C.jlong, jMaxItems C.jint, jMaxBytes C.jint, jWindow C.jobject, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	window, err := jutil.GoDuration(env, jutil.Object(uintptr(unsafe.Pointer(jWindow))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	stream := *(*rpc.Stream)(jutil.GoRefValue(jutil.Ref(goRef)))
+	b := startRecvBatcher(stream, func() ([]byte, error) {
+		return recvVom(stream)
+	})
+	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
+		batch, err := b.next(int(jMaxItems), int(jMaxBytes), window)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		jBatch, err := jutil.JByteArrayArray(env, batch)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		// Must grab a global reference as we free up the env and all local references that come along
+		// with it.
+		return jutil.NewGlobalRef(env, jBatch), nil // Un-refed in DoAsyncCall
+	})
+}
+
 //export Java_io_v_impl_google_rpc_StreamImpl_nativeFinalize
 func Java_io_v_impl_google_rpc_StreamImpl_nativeFinalize(jenv *C.JNIEnv, jStream C.jobject, goRef C.jlong) {
 	jutil.GoDecRef(jutil.Ref(goRef))
@@ -395,7 +759,7 @@ func Java_io_v_impl_google_rpc_ClientCallImpl_nativeCloseSend(jenv *C.JNIEnv, jC
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
 	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
-		return jutil.NullObject, (*(*rpc.ClientCall)(jutil.GoRefValue(jutil.Ref(goRef)))).CloseSend()
+		return jutil.NullObject, (*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).CloseSend()
 	})
 }
 
@@ -406,7 +770,7 @@ func doFinish(goRef C.jlong, numResults int) (jutil.Object, error) {
 		value := new(vdl.Value)
 		resultPtrs[i] = &value
 	}
-	if err := (*(*rpc.ClientCall)(jutil.GoRefValue(jutil.Ref(goRef)))).Finish(resultPtrs...); err != nil {
+	if err := (*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).Finish(resultPtrs...); err != nil {
 		// Invocation error.
 		return jutil.NullObject, err
 	}
@@ -442,6 +806,64 @@ func Java_io_v_impl_google_rpc_ClientCallImpl_nativeFinish(jenv *C.JNIEnv, jCall
 	})
 }
 
+//export Java_io_v_impl_google_rpc_ClientCallImpl_nativeCancel
+func Java_io_v_impl_google_rpc_ClientCallImpl_nativeCancel(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) {
+	(*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).Cancel()
+}
+
+//export Java_io_v_impl_google_rpc_ClientCallImpl_nativeClose
+func Java_io_v_impl_google_rpc_ClientCallImpl_nativeClose(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) {
+	(*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).Close()
+}
+
+//export Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteBlessings
+func Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteBlessings(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	_, blessings, err := (*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).RemoteBlessings()
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jBlessings, err := jsecurity.JavaBlessings(env, blessings)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jBlessings))
+}
+
+//export Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteBlessingNames
+func Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteBlessingNames(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) C.jobjectArray {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	names, _, err := (*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).RemoteBlessings()
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jNames, err := jutil.JStringArray(env, names)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobjectArray(unsafe.Pointer(jNames))
+}
+
+//export Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteEndpoint
+func Java_io_v_impl_google_rpc_ClientCallImpl_nativeRemoteEndpoint(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	endpoint, err := (*clientCall)(jutil.GoRefValue(jutil.Ref(goRef))).RemoteEndpoint()
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jEndpoint, err := jnaming.JavaEndpoint(env, endpoint)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jEndpoint))
+}
+
 //export Java_io_v_impl_google_rpc_ClientCallImpl_nativeFinalize
 func Java_io_v_impl_google_rpc_ClientCallImpl_nativeFinalize(jenv *C.JNIEnv, jCall C.jobject, goRef C.jlong) {
 	jutil.GoDecRef(jutil.Ref(goRef))
@@ -525,6 +947,56 @@ func Java_io_v_impl_google_rpc_StreamServerCallImpl_nativeFinalize(jenv *C.JNIEn
 	jutil.GoDecRef(jutil.Ref(goRef))
 }
 
+//export Java_io_v_impl_google_rpc_CallRecorder_nativeStart
+func Java_io_v_impl_google_rpc_CallRecorder_nativeStart(jenv *C.JNIEnv, jCallRecorderClass C.jclass, jPath C.jstring) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	if err := StartRecording(jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jPath))))); err != nil {
+		jutil.JThrowV(env, err)
+	}
+}
+
+//export Java_io_v_impl_google_rpc_CallRecorder_nativeStop
+func Java_io_v_impl_google_rpc_CallRecorder_nativeStop(jenv *C.JNIEnv, jCallRecorderClass C.jclass) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	if err := StopRecording(); err != nil {
+		jutil.JThrowV(env, err)
+	}
+}
+
+//export Java_io_v_impl_google_rpc_CallRecorder_nativeReplay
+func Java_io_v_impl_google_rpc_CallRecorder_nativeReplay(jenv *C.JNIEnv, jCallRecorderClass C.jclass, jContext C.jobject, jDispatcher C.jobject, jPath C.jstring, jCallbackObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	d, err := GoDispatcher(env, jutil.Object(uintptr(unsafe.Pointer(jDispatcher))), jutil.NullObject)
+	if err != nil {
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	path := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jPath))))
+	// Replayed calls invoke the Java dispatcher, so they must not block the calling thread.
+	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
+		recs, err := ReadRecording(path)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		diffs := Replay(ctx, d, recs)
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		jDiffs, err := jutil.JStringArray(env, diffs)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		// Must grab a global reference as we free up the env and all local references that come
+		// along with it.
+		return jutil.NewGlobalRef(env, jDiffs), nil // Un-refed in DoAsyncCall
+	})
+}
+
 //export Java_io_v_impl_google_rpc_AddressChooserImpl_nativeChoose
 func Java_io_v_impl_google_rpc_AddressChooserImpl_nativeChoose(jenv *C.JNIEnv, jAddressChooser C.jobject, goRef C.jlong, jProtocol C.jstring, jCandidates C.jobjectArray) C.jobjectArray {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
@@ -0,0 +1,166 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"container/list"
+	"fmt"
+	"path"
+	"sync"
+
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+)
+
+const defaultLookupCacheSize = 1024
+
+// lookupCache is an LRU cache of dispatcher lookup results, keyed by suffix.
+// Only the results for suffixes that match one of the cache's patterns (in
+// path.Match syntax) are cached.
+type lookupCache struct {
+	patterns []string
+	size     int
+
+	mu      sync.Mutex
+	lru     *list.List // of *lookupEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type lookupEntry struct {
+	suffix  string
+	invoker interface{}
+	auth    security.Authorizer
+}
+
+// newLookupCache creates a cache holding at most size lookup results for
+// suffixes matching the provided patterns.  Non-positive sizes select the
+// default cache size.
+func newLookupCache(patterns []string, size int) (*lookupCache, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid cacheable suffix pattern %q: %v", p, err)
+		}
+	}
+	if size <= 0 {
+		size = defaultLookupCacheSize
+	}
+	return &lookupCache{
+		patterns: patterns,
+		size:     size,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}, nil
+}
+
+// cacheable returns true iff the lookup results for the provided suffix
+// should be cached.
+func (c *lookupCache) cacheable(suffix string) bool {
+	for _, p := range c.patterns {
+		if ok, _ := path.Match(p, suffix); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *lookupCache) get(suffix string) (interface{}, security.Authorizer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[suffix]
+	if !ok {
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*lookupEntry)
+	return entry.invoker, entry.auth, true
+}
+
+func (c *lookupCache) put(suffix string, invoker interface{}, auth security.Authorizer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[suffix]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[suffix] = c.lru.PushFront(&lookupEntry{suffix, invoker, auth})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lookupEntry).suffix)
+	}
+}
+
+// methodCache memoizes the method tags and signatures of a single invoker.
+// Failed fetches aren't memoized.
+type methodCache struct {
+	mu         sync.Mutex
+	tags       map[string][]*vdl.Value
+	sig        []signature.Interface
+	methodSigs map[string]signature.Method
+}
+
+func newMethodCache() *methodCache {
+	return &methodCache{
+		tags:       make(map[string][]*vdl.Value),
+		methodSigs: make(map[string]signature.Method),
+	}
+}
+
+// getTags returns the memoized tags of the provided method, invoking fetch
+// to obtain them on the first call.
+func (c *methodCache) getTags(method string, fetch func() ([]*vdl.Value, error)) ([]*vdl.Value, error) {
+	c.mu.Lock()
+	tags, ok := c.tags[method]
+	c.mu.Unlock()
+	if ok {
+		return tags, nil
+	}
+	tags, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.tags[method] = tags
+	c.mu.Unlock()
+	return tags, nil
+}
+
+// getSignature returns the memoized signature of the invoker, invoking fetch
+// to obtain it on the first call.
+func (c *methodCache) getSignature(fetch func() ([]signature.Interface, error)) ([]signature.Interface, error) {
+	c.mu.Lock()
+	sig := c.sig
+	c.mu.Unlock()
+	if sig != nil {
+		return sig, nil
+	}
+	sig, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.sig = sig
+	c.mu.Unlock()
+	return sig, nil
+}
+
+// getMethodSignature returns the memoized signature of the provided method,
+// invoking fetch to obtain it on the first call.
+func (c *methodCache) getMethodSignature(method string, fetch func() (signature.Method, error)) (signature.Method, error) {
+	c.mu.Lock()
+	sig, ok := c.methodSigs[method]
+	c.mu.Unlock()
+	if ok {
+		return sig, nil
+	}
+	sig, err := fetch()
+	if err != nil {
+		return signature.Method{}, err
+	}
+	c.mu.Lock()
+	c.methodSigs[method] = sig
+	c.mu.Unlock()
+	return sig, nil
+}
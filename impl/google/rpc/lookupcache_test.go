@@ -0,0 +1,89 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"testing"
+
+	"v.io/v23/vdl"
+	"v.io/v23/vdlroot/signature"
+)
+
+func TestLookupCache(t *testing.T) {
+	c, err := newLookupCache([]string{"users/*", "stats"}, 2)
+	if err != nil {
+		t.Fatalf("couldn't create cache: %v", err)
+	}
+	for suffix, want := range map[string]bool{
+		"users/alice":     true,
+		"users/alice/box": false,
+		"users":           false,
+		"stats":           true,
+		"":                false,
+	} {
+		if got := c.cacheable(suffix); got != want {
+			t.Errorf("cacheable(%q): got %t, want %t", suffix, got, want)
+		}
+	}
+	c.put("users/alice", "alice", nil)
+	c.put("users/bob", "bob", nil)
+	if obj, _, ok := c.get("users/alice"); !ok || obj != "alice" {
+		t.Errorf("got (%v, %t), want (alice, true)", obj, ok)
+	}
+	// Alice was used more recently than Bob, so Bob is evicted.
+	c.put("stats", "stats", nil)
+	if _, _, ok := c.get("users/bob"); ok {
+		t.Errorf("least recently used entry wasn't evicted")
+	}
+	for _, suffix := range []string{"users/alice", "stats"} {
+		if _, _, ok := c.get(suffix); !ok {
+			t.Errorf("entry for %q was evicted", suffix)
+		}
+	}
+	if _, err := newLookupCache([]string{"users/["}, 0); err == nil {
+		t.Errorf("expected error for an invalid pattern")
+	}
+}
+
+func TestMethodCache(t *testing.T) {
+	c := newMethodCache()
+	fetches := 0
+	errFetch := errors.New("fetch failed")
+	fail := true
+	fetchTags := func() ([]*vdl.Value, error) {
+		fetches++
+		if fail {
+			return nil, errFetch
+		}
+		return []*vdl.Value{}, nil
+	}
+	if _, err := c.getTags("Get", fetchTags); err != errFetch {
+		t.Fatalf("got error %v, want %v", err, errFetch)
+	}
+	fail = false
+	for i := 0; i < 3; i++ {
+		if _, err := c.getTags("Get", fetchTags); err != nil {
+			t.Fatalf("getTags failed: %v", err)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("tags fetched %d times, want 2", fetches)
+	}
+	sigFetches := 0
+	for i := 0; i < 3; i++ {
+		c.getSignature(func() ([]signature.Interface, error) {
+			sigFetches++
+			return []signature.Interface{{}}, nil
+		})
+		c.getMethodSignature("Get", func() (signature.Method, error) {
+			sigFetches++
+			return signature.Method{}, nil
+		})
+	}
+	if sigFetches != 2 {
+		t.Errorf("signatures fetched %d times, want 2", sigFetches)
+	}
+}
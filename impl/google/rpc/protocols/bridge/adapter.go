@@ -0,0 +1,368 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/flow"
+	"v.io/x/ref/runtime/protocols/lib/framer"
+)
+
+var errListenerClosed = errors.New("listener closed")
+
+// protocolImpl is the contract of a Java Protocol object, as seen from Go.
+// The JNI implementation forwards every call to Java; tests use an in-memory
+// simulation instead.
+type protocolImpl interface {
+	dial(ctx *context.T, address string, timeout time.Duration) (streamImpl, error)
+	// resolve resolves a human-friendly name (e.g., a device alias, the
+	// name of a paired device or a discovery advertisement id) to one or
+	// more addresses.  Addresses resolve to themselves.
+	resolve(ctx *context.T, address string) ([]string, error)
+	listen(ctx *context.T, address string) (listenerImpl, error)
+	// release releases the resources (e.g., Java references) held by the
+	// protocol.  It is invoked once the protocol is no longer in use.
+	release()
+}
+
+// listenerImpl is the contract of a Java Protocol$Listener object.
+type listenerImpl interface {
+	accept() (streamImpl, error)
+	address() string
+	close() error
+	release()
+}
+
+// streamImpl is the contract of a Java Protocol$Stream object.  A read
+// returns at most max bytes.
+type streamImpl interface {
+	read(max int) ([]byte, error)
+	write(b []byte) error
+	close() error
+	localAddress() string
+	remoteAddress() string
+	release()
+}
+
+// registerProtocol registers the provided protocol implementation with the
+// flow layer under the given name.
+func registerProtocol(name string, impl protocolImpl) {
+	p := newProtocolAdapter(name, impl)
+	adapters.Lock()
+	adapters.byName[name] = p
+	adapters.Unlock()
+	flow.RegisterProtocol(name, p)
+}
+
+func newProtocolAdapter(name string, impl protocolImpl) *protocolAdapter {
+	p := &protocolAdapter{name, impl, newResolveCache(defaultResolveTTL)}
+	// The protocol is released once it is replaced and no longer in use.
+	runtime.SetFinalizer(p, func(p *protocolAdapter) { p.impl.release() })
+	return p
+}
+
+// protocolAdapter adapts a protocolImpl to flow.Protocol.
+type protocolAdapter struct {
+	name  string
+	impl  protocolImpl
+	cache *resolveCache
+}
+
+// Dial dials the provided address.  It returns once the dial completes, the
+// timeout expires or the context is done, whichever comes first; the stream
+// of a dial that completes after Dial returned is closed.
+func (p *protocolAdapter) Dial(ctx *context.T, protocol, address string, timeout time.Duration) (flow.Conn, error) {
+	results := make(chan streamResult)
+	abandoned := make(chan struct{})
+	go func() {
+		s, err := p.impl.dial(ctx, address, timeout)
+		select {
+		case results <- streamResult{s, err}:
+		case <-abandoned:
+			if err == nil {
+				s.close()
+				s.release()
+			}
+		}
+	}()
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case r := <-results:
+		if r.err != nil {
+			p.cache.invalidate(address)
+			return nil, r.err
+		}
+		return newConnection(p.name, r.stream), nil
+	case <-expired:
+		close(abandoned)
+		p.cache.invalidate(address)
+		return nil, deadlineError{}
+	case <-ctx.Done():
+		close(abandoned)
+		return nil, ctx.Err()
+	}
+}
+
+// Resolve resolves the provided name, e.g., a device alias, to the addresses
+// to dial.  Resolutions are cached; the cached resolutions that include an
+// address that fails to be dialed are dropped.
+func (p *protocolAdapter) Resolve(ctx *context.T, protocol, address string) (string, []string, error) {
+	if addrs, ok := p.cache.lookup(address); ok {
+		return protocol, addrs, nil
+	}
+	addrs, err := p.impl.resolve(ctx, address)
+	if err != nil {
+		return "", nil, err
+	}
+	p.cache.insert(address, addrs)
+	return protocol, addrs, nil
+}
+
+func (p *protocolAdapter) Listen(ctx *context.T, protocol, address string) (flow.Listener, error) {
+	l, err := p.impl.listen(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	return newListener(p.name, l), nil
+}
+
+func newListener(protocol string, impl listenerImpl) flow.Listener {
+	l := &listener{
+		protocol: protocol,
+		impl:     impl,
+		addr:     &netAddr{protocol, impl.address()},
+		results:  make(chan streamResult),
+		closed:   make(chan struct{}),
+	}
+	// Close releases the listener; the finalizer only does so for listeners
+	// that were never closed.
+	runtime.SetFinalizer(l, func(l *listener) { l.release() })
+	return l
+}
+
+// streamResult is the result of a dial or accept.
+type streamResult struct {
+	stream streamImpl
+	err    error
+}
+
+// listener adapts a listenerImpl to flow.Listener.  At most one accept is
+// pending on the implementation at any time; its result is handed to the
+// first Accept call waiting for it, so that Accept calls that gave up (e.g.,
+// because their context was canceled) don't lose connections.
+type listener struct {
+	protocol string
+	impl     listenerImpl
+	addr     net.Addr
+	results  chan streamResult // results of the pending accept
+
+	closeOnce   sync.Once
+	releaseOnce sync.Once
+	closed      chan struct{}
+
+	mu        sync.Mutex
+	accepting bool // whether an accept is pending
+	isClosed  bool
+}
+
+// Accept accepts a connection.  It returns once a connection is accepted, the
+// context is done or the listener is closed, whichever comes first.
+func (l *listener) Accept(ctx *context.T) (flow.Conn, error) {
+	l.mu.Lock()
+	if l.isClosed {
+		l.mu.Unlock()
+		return nil, errListenerClosed
+	}
+	if !l.accepting {
+		l.accepting = true
+		go l.accept()
+	}
+	l.mu.Unlock()
+	select {
+	case r := <-l.results:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return newConnection(l.protocol, r.stream), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+// accept runs an accept on the implementation and waits for an Accept call to
+// take its result.
+func (l *listener) accept() {
+	s, err := l.impl.accept()
+	l.mu.Lock()
+	l.accepting = false
+	isClosed := l.isClosed
+	l.mu.Unlock()
+	if !isClosed {
+		select {
+		case l.results <- streamResult{s, err}:
+			return
+		case <-l.closed:
+		}
+	}
+	if err == nil {
+		s.close()
+		s.release()
+	}
+	// The listener was closed while this accept was pending.
+	l.release()
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Close closes the listener, which fails pending and future Accept calls.
+// The listener is released right away, or as soon as the pending accept
+// returns.
+func (l *listener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.isClosed = true
+		accepting := l.accepting
+		l.mu.Unlock()
+		close(l.closed)
+		err = l.impl.close()
+		if !accepting {
+			l.release()
+		}
+	})
+	return err
+}
+
+func (l *listener) release() {
+	l.releaseOnce.Do(l.impl.release)
+}
+
+// newConnection creates a new Go connection over the provided stream.
+func newConnection(protocol string, impl streamImpl) flow.Conn {
+	local, remote := &netAddr{protocol, impl.localAddress()}, &netAddr{protocol, impl.remoteAddress()}
+	stats := newConnStats(protocol, local.addr, remote.addr)
+	c := &readWriteCloser{impl, stats}
+	runtime.SetFinalizer(c, func(c *readWriteCloser) {
+		c.stats.unregister()
+		c.impl.release()
+	})
+	// Buffer the stream, so that every message crosses into Java about once
+	// rather than once per read and write of the framer.
+	stream := newBufferedStream(c, 0, 0, 0)
+	return &conn{MsgReadWriteCloser: framer.New(stream), stream: stream, stats: stats, localAddr: local, remoteAddr: remote}
+}
+
+type conn struct {
+	flow.MsgReadWriteCloser
+	stream     *bufferedStream
+	stats      *connStats
+	localAddr  net.Addr
+	remoteAddr net.Addr
+	closed     int32 // set once Close is called
+}
+
+// ReadMsg reads a message.  The end of the stream, and the failure of reads
+// pending when the connection is closed locally, aren't read errors.
+func (c *conn) ReadMsg() ([]byte, error) {
+	msg, err := c.MsgReadWriteCloser.ReadMsg()
+	if err != nil && (err == io.EOF || atomic.LoadInt32(&c.closed) != 0) {
+		return msg, err
+	}
+	c.stats.frameRead(err)
+	return msg, err
+}
+
+// WriteMsg writes a message and flushes it to the stream.
+func (c *conn) WriteMsg(data ...[]byte) (int, error) {
+	n, err := c.MsgReadWriteCloser.WriteMsg(data...)
+	if err == nil {
+		err = c.stream.Flush()
+	}
+	c.stats.frameWritten(err)
+	return n, err
+}
+
+// Close closes the connection, removing it from the active connections.
+func (c *conn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	c.stats.unregister()
+	return c.MsgReadWriteCloser.Close()
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// SetReadDeadline sets the deadline of pending and future message reads.
+func (c *conn) SetReadDeadline(t time.Time) {
+	c.stream.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline of future message writes.
+func (c *conn) SetWriteDeadline(t time.Time) {
+	c.stream.SetWriteDeadline(t)
+}
+
+// readWriteCloser adapts a streamImpl to io.ReadWriteCloser.
+type readWriteCloser struct {
+	impl  streamImpl
+	stats *connStats
+}
+
+func (c *readWriteCloser) Read(b []byte) (n int, err error) {
+	start := time.Now()
+	data, err := c.impl.read(len(b))
+	if err != nil {
+		return 0, err
+	}
+	c.stats.streamRead(len(data), time.Since(start))
+	return copy(b, data), nil
+}
+
+func (c *readWriteCloser) Write(b []byte) (n int, err error) {
+	start := time.Now()
+	if err := c.impl.write(b); err != nil {
+		return 0, err
+	}
+	c.stats.streamWrite(len(b), time.Since(start))
+	return len(b), nil
+}
+
+func (c *readWriteCloser) Close() error {
+	return c.impl.close()
+}
+
+type netAddr struct {
+	protocol, addr string
+}
+
+func (a *netAddr) Network() string {
+	return a.protocol
+}
+
+func (a *netAddr) String() string {
+	return a.addr
+}
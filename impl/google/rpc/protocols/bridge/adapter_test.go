@@ -0,0 +1,128 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+)
+
+func TestAcceptContext(t *testing.T) {
+	root, cancel := context.RootContext()
+	defer cancel()
+	p := newProtocolAdapter("sim", NewLoopback(LoopbackOptions{}))
+	ln, err := p.Listen(root, "sim", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	ctx, _ := context.WithTimeout(root, 10*time.Millisecond)
+	if _, err := ln.Accept(ctx); err != ctx.Err() || err == nil {
+		t.Errorf("got error %v, want %v", err, ctx.Err())
+	}
+	ctx, cancelAccept := context.WithCancel(root)
+	errs := make(chan error)
+	go func() {
+		_, err := ln.Accept(ctx)
+		errs <- err
+	}()
+	cancelAccept()
+	if err := <-errs; err != ctx.Err() {
+		t.Errorf("got error %v, want %v", err, ctx.Err())
+	}
+	// The connection accepted by the accept left pending by the calls above
+	// isn't lost.
+	dialed, err := p.Dial(root, "sim", ln.Addr().String(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted, err := ln.Accept(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialed.WriteMsg([]byte("hi"))
+	if got, err := accepted.ReadMsg(); err != nil || string(got) != "hi" {
+		t.Errorf("got message %q, error %v, want %q", got, err, "hi")
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{})
+	p := newProtocolAdapter("sim", l)
+	ln, err := p.Listen(ctx, "sim", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	errs := make(chan error)
+	go func() {
+		_, err := ln.Accept(ctx)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := ln.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != errListenerClosed {
+		t.Errorf("got pending accept error %v, want %v", err, errListenerClosed)
+	}
+	if _, err := ln.Accept(ctx); err != errListenerClosed {
+		t.Errorf("got error %v, want %v", err, errListenerClosed)
+	}
+	if got := ln.Addr().String(); got != addr {
+		t.Errorf("got address %q after close, want %q", got, addr)
+	}
+	// The listener is released without waiting for GC.
+	for deadline := time.Now().Add(time.Second); l.Outstanding() > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := l.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding listeners after close, want 0", got)
+	}
+	if err := ln.Close(); err != nil {
+		t.Errorf("second close failed: %v", err)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	root, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{DialLatency: 50 * time.Millisecond})
+	p := newProtocolAdapter("sim", l)
+	ln, err := p.Listen(root, "sim", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	start := time.Now()
+	if _, err := p.Dial(root, "sim", ln.Addr().String(), 10*time.Millisecond); err != (deadlineError{}) {
+		t.Errorf("got error %v, want %v", err, deadlineError{})
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("dial returned after %v, past its timeout", elapsed)
+	}
+	ctx, cancelDial := context.WithCancel(root)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancelDial()
+	}()
+	if _, err := p.Dial(ctx, "sim", ln.Addr().String(), 0); err != ctx.Err() || err == nil {
+		t.Errorf("got error %v, want %v", err, ctx.Err())
+	}
+	// The stream of the dial that timed out is closed once the dial
+	// completes.
+	accepted, err := ln.Accept(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accepted.ReadMsg(); err != io.EOF {
+		t.Errorf("got error %v, want %v", err, io.EOF)
+	}
+}
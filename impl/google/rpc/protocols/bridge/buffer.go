@@ -0,0 +1,309 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultReadAhead is the default size of the read-ahead buffer.
+	defaultReadAhead = 64 << 10
+	// defaultReadChunk is the default maximum number of bytes requested from
+	// the underlying stream in a single read.
+	defaultReadChunk = 16 << 10
+	// defaultWriteBuffer is the default number of buffered bytes past which
+	// writes are flushed even if no frame boundary was reached.
+	defaultWriteBuffer = 64 << 10
+)
+
+var errStreamClosed = errors.New("stream closed")
+
+// deadlineError is returned by operations whose deadline expired.  It
+// implements net.Error.
+type deadlineError struct{}
+
+func (deadlineError) Error() string   { return "i/o deadline exceeded" }
+func (deadlineError) Timeout() bool   { return true }
+func (deadlineError) Temporary() bool { return true }
+
+// bufferedStream minimizes the calls made on an underlying stream whose every
+// call is expensive (e.g., crosses into Java).  Reads are served from a
+// read-ahead ring buffer, filled by a background goroutine in chunks larger
+// than the reads; writes are coalesced until Flush is invoked (i.e., at frame
+// boundaries) or the write buffer fills up.  Reads and writes can be given
+// deadlines; a write that misses its deadline breaks the stream for writing,
+// as the data written by it is in an unknown state.
+type bufferedStream struct {
+	raw       io.ReadWriteCloser
+	readChunk int
+	maxWrite  int
+
+	readOnce  sync.Once
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	mu           sync.Mutex
+	ring         ringBuffer
+	readErr      error         // error returned by the underlying stream, if any
+	readable     chan struct{} // signaled when data or an error is available
+	space        chan struct{} // signaled when the ring buffer has room
+	readDeadline time.Time
+
+	wmu           sync.Mutex // held while writing or flushing
+	wbuf          []byte
+	writeErr      error // sticky write error, if any
+	writeDeadline time.Time
+}
+
+// newBufferedStream creates a buffered stream over the provided raw stream.
+// Non-positive sizes select the defaults.
+func newBufferedStream(raw io.ReadWriteCloser, readAhead, readChunk, writeBuffer int) *bufferedStream {
+	if readAhead <= 0 {
+		readAhead = defaultReadAhead
+	}
+	if readChunk <= 0 {
+		readChunk = defaultReadChunk
+	}
+	if readChunk > readAhead {
+		readChunk = readAhead
+	}
+	if writeBuffer <= 0 {
+		writeBuffer = defaultWriteBuffer
+	}
+	return &bufferedStream{
+		raw:       raw,
+		readChunk: readChunk,
+		maxWrite:  writeBuffer,
+		closed:    make(chan struct{}),
+		ring:      ringBuffer{buf: make([]byte, readAhead)},
+		readable:  make(chan struct{}, 1),
+		space:     make(chan struct{}, 1),
+	}
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// readAhead fills the ring buffer from the raw stream until the raw stream
+// fails.
+func (s *bufferedStream) readAhead() {
+	chunk := make([]byte, s.readChunk)
+	for {
+		s.mu.Lock()
+		free := s.ring.free()
+		s.mu.Unlock()
+		if free == 0 {
+			select {
+			case <-s.space:
+				continue
+			case <-s.closed:
+				return
+			}
+		}
+		if free > len(chunk) {
+			free = len(chunk)
+		}
+		n, err := s.raw.Read(chunk[:free])
+		s.mu.Lock()
+		s.ring.write(chunk[:n])
+		if err != nil {
+			s.readErr = err
+		}
+		s.mu.Unlock()
+		signal(s.readable)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Read reads buffered data, waiting for data to be read ahead if there is
+// none.
+func (s *bufferedStream) Read(b []byte) (int, error) {
+	s.readOnce.Do(func() { go s.readAhead() })
+	for {
+		s.mu.Lock()
+		if s.ring.len() > 0 {
+			n := s.ring.read(b)
+			s.mu.Unlock()
+			signal(s.space)
+			return n, nil
+		}
+		if err := s.readErr; err != nil {
+			s.mu.Unlock()
+			return 0, err
+		}
+		deadline := s.readDeadline
+		s.mu.Unlock()
+		if err := wait(s.readable, s.closed, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// wait waits for ch to be signaled, or returns an error if closed is closed
+// or the deadline expires first.
+func wait(ch, closed chan struct{}, deadline time.Time) error {
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		d := deadline.Sub(time.Now())
+		if d <= 0 {
+			return deadlineError{}
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-closed:
+		return errStreamClosed
+	case <-expired:
+		return deadlineError{}
+	}
+}
+
+// Write buffers the provided data, flushing the buffer if it fills up.
+func (s *bufferedStream) Write(b []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	if s.writeErr != nil {
+		return 0, s.writeErr
+	}
+	s.wbuf = append(s.wbuf, b...)
+	if len(s.wbuf) >= s.maxWrite {
+		if err := s.flushLocked(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush writes all buffered data to the raw stream in a single call.
+func (s *bufferedStream) Flush() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	return s.flushLocked()
+}
+
+func (s *bufferedStream) flushLocked() error {
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	if len(s.wbuf) == 0 {
+		return nil
+	}
+	data := s.wbuf
+	s.wbuf = nil
+	if s.writeDeadline.IsZero() {
+		_, err := s.raw.Write(data)
+		if err != nil {
+			s.writeErr = err
+		}
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.raw.Write(data)
+		done <- err
+	}()
+	var err error
+	if d := s.writeDeadline.Sub(time.Now()); d <= 0 {
+		err = deadlineError{}
+	} else {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case err = <-done:
+		case <-timer.C:
+			err = deadlineError{}
+		}
+	}
+	if err != nil {
+		s.writeErr = err
+	}
+	return err
+}
+
+// SetReadDeadline sets the deadline of pending and future reads.  The zero
+// time means no deadline.
+func (s *bufferedStream) SetReadDeadline(t time.Time) {
+	s.mu.Lock()
+	s.readDeadline = t
+	s.mu.Unlock()
+	// Wake up pending reads so that they notice the new deadline.
+	signal(s.readable)
+}
+
+// SetWriteDeadline sets the deadline of future writes and flushes.  The zero
+// time means no deadline.
+func (s *bufferedStream) SetWriteDeadline(t time.Time) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.writeDeadline = t
+}
+
+// Close flushes the buffered data and closes the raw stream.  Pending reads
+// fail.
+func (s *bufferedStream) Close() error {
+	err := s.Flush()
+	s.closeOnce.Do(func() { close(s.closed) })
+	if cerr := s.raw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ringBuffer is a fixed-size FIFO byte buffer.
+type ringBuffer struct {
+	buf  []byte
+	r, n int // read position and number of buffered bytes
+}
+
+func (b *ringBuffer) len() int  { return b.n }
+func (b *ringBuffer) free() int { return len(b.buf) - b.n }
+
+// write appends as much of p as fits, returning the number of bytes appended.
+func (b *ringBuffer) write(p []byte) int {
+	total := 0
+	for len(p) > 0 && b.free() > 0 {
+		w := (b.r + b.n) % len(b.buf)
+		end := len(b.buf)
+		if w < b.r {
+			end = b.r
+		}
+		c := copy(b.buf[w:end], p)
+		b.n += c
+		p = p[c:]
+		total += c
+	}
+	return total
+}
+
+// read removes up to len(p) bytes into p, returning their number.
+func (b *ringBuffer) read(p []byte) int {
+	total := 0
+	for len(p) > 0 && b.n > 0 {
+		end := b.r + b.n
+		if end > len(b.buf) {
+			end = len(b.buf)
+		}
+		c := copy(p, b.buf[b.r:end])
+		b.r = (b.r + c) % len(b.buf)
+		b.n -= c
+		p = p[c:]
+		total += c
+	}
+	return total
+}
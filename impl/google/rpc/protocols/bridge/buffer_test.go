@@ -0,0 +1,196 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// crossingStream simulates a Java stream: every call on it is counted and
+// costs the provided time, on top of the cost of the wrapped stream.
+type crossingStream struct {
+	io.ReadWriteCloser
+	cost          time.Duration
+	reads, writes int32
+}
+
+func (s *crossingStream) cross() {
+	for start := time.Now(); time.Since(start) < s.cost; {
+	}
+}
+
+func (s *crossingStream) Read(b []byte) (int, error) {
+	atomic.AddInt32(&s.reads, 1)
+	s.cross()
+	return s.ReadWriteCloser.Read(b)
+}
+
+func (s *crossingStream) Write(b []byte) (int, error) {
+	atomic.AddInt32(&s.writes, 1)
+	s.cross()
+	return s.ReadWriteCloser.Write(b)
+}
+
+// newCrossingPipe returns the two ends of a pipe whose every call is a
+// simulated crossing into Java.
+func newCrossingPipe(cost time.Duration) (*crossingStream, *crossingStream) {
+	a, b := net.Pipe()
+	return &crossingStream{ReadWriteCloser: a, cost: cost}, &crossingStream{ReadWriteCloser: b, cost: cost}
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := ringBuffer{buf: make([]byte, 5)}
+	if n := r.write([]byte("abc")); n != 3 {
+		t.Fatalf("wrote %d bytes, want 3", n)
+	}
+	p := make([]byte, 2)
+	if n := r.read(p); n != 2 || string(p) != "ab" {
+		t.Fatalf("read %q, want %q", p[:n], "ab")
+	}
+	// Wrap around.
+	if n := r.write([]byte("defgh")); n != 4 {
+		t.Fatalf("wrote %d bytes, want 4", n)
+	}
+	p = make([]byte, 10)
+	if n := r.read(p); string(p[:n]) != "cdefg" {
+		t.Errorf("read %q, want %q", p[:n], "cdefg")
+	}
+	if r.len() != 0 || r.free() != 5 {
+		t.Errorf("got %d buffered and %d free bytes, want 0 and 5", r.len(), r.free())
+	}
+}
+
+func TestBufferedStreamCoalescesWrites(t *testing.T) {
+	a, b := newCrossingPipe(0)
+	w, r := newBufferedStream(a, 0, 0, 0), newBufferedStream(b, 0, 0, 0)
+	go func() {
+		for _, s := range []string{"hdr", "hello, ", "world"} {
+			w.Write([]byte(s))
+		}
+		w.Flush()
+	}()
+	got := make([]byte, len("hdrhello, world"))
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hdrhello, world" {
+		t.Errorf("got %q, want %q", got, "hdrhello, world")
+	}
+	if writes := atomic.LoadInt32(&a.writes); writes != 1 {
+		t.Errorf("got %d writes to the raw stream, want 1", writes)
+	}
+	// The data arrives in a single read from the raw stream, but reads
+	// ahead may have started since.
+	if reads := atomic.LoadInt32(&b.reads); reads > 2 {
+		t.Errorf("got %d reads from the raw stream, want at most 2", reads)
+	}
+	w.Close()
+	if _, err := r.Read(got); err == nil {
+		t.Errorf("read succeeded after the other end was closed")
+	}
+}
+
+func TestBufferedStreamFlushesFullBuffer(t *testing.T) {
+	a, b := newCrossingPipe(0)
+	w := newBufferedStream(a, 0, 0, 4)
+	go io.Copy(ioutil.Discard, b)
+	w.Write([]byte("abc"))
+	if writes := atomic.LoadInt32(&a.writes); writes != 0 {
+		t.Errorf("got %d writes to the raw stream before the buffer filled up, want 0", writes)
+	}
+	w.Write([]byte("de"))
+	if writes := atomic.LoadInt32(&a.writes); writes != 1 {
+		t.Errorf("got %d writes to the raw stream after the buffer filled up, want 1", writes)
+	}
+}
+
+func TestBufferedStreamDeadlines(t *testing.T) {
+	a, _ := newCrossingPipe(0)
+	s := newBufferedStream(a, 0, 0, 0)
+	s.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := s.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("got read error %v, want a timeout", err)
+	}
+
+	// Nobody reads from the other end, so the flush blocks.
+	s.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))
+	s.Write([]byte("abc"))
+	if err := s.Flush(); err != (deadlineError{}) {
+		t.Errorf("got flush error %v, want %v", err, deadlineError{})
+	}
+	// The stream is broken for writing.
+	s.SetWriteDeadline(time.Time{})
+	if _, err := s.Write([]byte("d")); err != (deadlineError{}) {
+		t.Errorf("got write error %v, want %v", err, deadlineError{})
+	}
+
+	// Closing the stream fails pending reads.
+	s.SetReadDeadline(time.Time{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Close()
+	}()
+	if _, err := s.Read(make([]byte, 1)); err == nil {
+		t.Errorf("read succeeded on a closed stream")
+	}
+}
+
+const (
+	benchCrossingCost = 5 * time.Microsecond
+	benchMsgSize      = 1024
+)
+
+// benchmarkFramedStream sends framed messages, i.e., a 3-byte header followed
+// by the payload, the way the framer does, over a pipe whose every call is a
+// simulated crossing into Java.
+func benchmarkFramedStream(b *testing.B, wrap func(io.ReadWriteCloser) io.ReadWriteCloser, flush func(io.Writer) error) {
+	a, c := newCrossingPipe(benchCrossingCost)
+	w, r := wrap(a), wrap(c)
+	msg := bytes.Repeat([]byte{'x'}, benchMsgSize)
+	hdr := make([]byte, 4)
+	binary.BigEndian.PutUint32(hdr, benchMsgSize)
+	go func() {
+		for i := 0; i < b.N; i++ {
+			w.Write(hdr[1:])
+			w.Write(msg)
+			flush(w)
+		}
+	}()
+	b.SetBytes(benchMsgSize)
+	b.ResetTimer()
+	buf := make([]byte, benchMsgSize)
+	for i := 0; i < b.N; i++ {
+		if _, err := io.ReadFull(r, buf[:3]); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	b.Logf("%d messages took %.2f crossings each", b.N, float64(atomic.LoadInt32(&a.writes)+atomic.LoadInt32(&c.reads))/float64(b.N))
+	w.Close()
+	r.Close()
+}
+
+func BenchmarkUnbufferedStream(b *testing.B) {
+	benchmarkFramedStream(b,
+		func(s io.ReadWriteCloser) io.ReadWriteCloser { return s },
+		func(io.Writer) error { return nil })
+}
+
+func BenchmarkBufferedStream(b *testing.B) {
+	benchmarkFramedStream(b,
+		func(s io.ReadWriteCloser) io.ReadWriteCloser { return newBufferedStream(s, 0, 0, 0) },
+		func(w io.Writer) error { return w.(*bufferedStream).Flush() })
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build java android
+
+package bridge
+
+import (
+	"unsafe"
+
+	jutil "v.io/x/jni/util"
+)
+
+// #include "jni.h"
+import "C"
+
+var (
+	// Global reference for io.v.impl.google.rpc.protocols.ConnectionInfo class.
+	jConnectionInfoClass jutil.Class
+)
+
+// Init initializes the JNI code with the given Java environment. This method
+// must be called from the main Java thread.
+func Init(env jutil.Env) error {
+	// Cache global references to all Java classes used by the package.  This is
+	// necessary because JNI gets access to the class loader only in the system
+	// thread, so we aren't able to invoke FindClass in other threads.
+	var err error
+	jConnectionInfoClass, err = jutil.JFindClass(env, "io/v/impl/google/rpc/protocols/ConnectionInfo")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// javaConnectionInfo converts the provided connection statistics into a Java
+// ConnectionInfo object.
+func javaConnectionInfo(env jutil.Env, s ConnStats) (jutil.Object, error) {
+	return jutil.NewObject(env, jConnectionInfoClass,
+		[]jutil.Sign{jutil.LongSign, jutil.StringSign, jutil.StringSign, jutil.StringSign, jutil.DateTimeSign, jutil.DurationSign, jutil.LongSign, jutil.LongSign, jutil.LongSign, jutil.LongSign, jutil.LongSign, jutil.LongSign, jutil.DurationSign, jutil.DurationSign, jutil.VExceptionSign},
+		int64(s.ID), s.Protocol, s.LocalAddr, s.RemoteAddr, s.Created, s.Lifetime, s.BytesRead, s.BytesWritten, s.FramesRead, s.FramesWritten, s.ReadErrors, s.WriteErrors, s.ReadLatency, s.WriteLatency, s.LastError)
+}
+
+//export Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeRegister
+func Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeRegister(jenv *C.JNIEnv, jProtocolRegistryClass C.jclass, jName C.jstring, jProtocol C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	if err := Register(env, name, jutil.Object(uintptr(unsafe.Pointer(jProtocol)))); err != nil {
+		jutil.JThrowV(env, err)
+	}
+}
+
+//export Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeConnections
+func Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeConnections(jenv *C.JNIEnv, jProtocolRegistryClass C.jclass) C.jobjectArray {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	conns := Connections()
+	jConns := make([]jutil.Object, len(conns))
+	for i, c := range conns {
+		var err error
+		if jConns[i], err = javaConnectionInfo(env, c); err != nil {
+			jutil.JThrowV(env, err)
+			return nil
+		}
+	}
+	jArr, err := jutil.JObjectArray(env, jConns, jConnectionInfoClass)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobjectArray(unsafe.Pointer(jArr))
+}
+
+//export Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeSetResolveTtl
+func Java_io_v_impl_google_rpc_protocols_ProtocolRegistry_nativeSetResolveTtl(jenv *C.JNIEnv, jProtocolRegistryClass C.jclass, jName C.jstring, jTTL C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	ttl, err := jutil.GoDuration(env, jutil.Object(uintptr(unsafe.Pointer(jTTL))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return
+	}
+	if err := SetResolveTTL(name, ttl); err != nil {
+		jutil.JThrowV(env, err)
+	}
+}
@@ -0,0 +1,428 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"v.io/v23/context"
+)
+
+var (
+	errDisconnected      = errors.New("connection lost")
+	errConnectionReset   = errors.New("connection reset by peer")
+	errAddressInUse      = errors.New("address already in use")
+	errConnectionRefused = errors.New("connection refused")
+)
+
+// loopbackBacklog is the number of dialed streams a loopback listener queues
+// before dials block.
+const loopbackBacklog = 8
+
+// LoopbackOptions configures the links of a Loopback protocol.
+type LoopbackOptions struct {
+	// Latency is the time it takes for a written packet to become readable
+	// at the other end.
+	Latency time.Duration
+	// MTU is the maximum size of a packet.  A write is split into packets of
+	// at most MTU bytes, and a read returns the data of at most one packet.
+	// Zero means unlimited.
+	MTU int
+	// LossRate is the probability with which each packet is silently
+	// dropped.
+	LossRate float64
+	// DialLatency is the time it takes for a dial to reach the listener,
+	// during which the dial can't be interrupted, like Java dials that don't
+	// honour their timeout.
+	DialLatency time.Duration
+	// Seed seeds the random source deciding which packets are lost.
+	Seed int64
+}
+
+// Loopback is an in-memory implementation of the contract of Java protocols,
+// e.g., bt and ble, whose streams are pipes with a configurable latency, MTU
+// and loss rate that can be disconnected at will.  Registered with
+// RegisterLoopback, it exercises the same adapter code as Java protocols do,
+// on any platform.
+type Loopback struct {
+	opts LoopbackOptions
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	listeners map[string]*loopbackListener
+	streams   map[*loopbackStream]bool
+	aliases   map[string][]string
+	nextID    int
+	resolves  int // number of resolutions
+
+	outstanding int32 // number of streams and listeners not yet released
+}
+
+// NewLoopback creates a new Loopback protocol with the provided options.
+func NewLoopback(opts LoopbackOptions) *Loopback {
+	return &Loopback{
+		opts:      opts,
+		rand:      rand.New(rand.NewSource(opts.Seed)),
+		listeners: make(map[string]*loopbackListener),
+		streams:   make(map[*loopbackStream]bool),
+		aliases:   make(map[string][]string),
+	}
+}
+
+// RegisterLoopback creates a new Loopback protocol with the provided options
+// and registers it with the flow layer under the given protocol name.
+func RegisterLoopback(name string, opts LoopbackOptions) *Loopback {
+	l := NewLoopback(opts)
+	registerProtocol(name, l)
+	return l
+}
+
+// Alias makes the provided name resolve to the provided addresses, or to
+// itself if there are none.
+func (l *Loopback) Alias(name string, addrs ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(addrs) == 0 {
+		delete(l.aliases, name)
+	} else {
+		l.aliases[name] = append([]string(nil), addrs...)
+	}
+}
+
+// Disconnect abruptly breaks all open streams: pending and future reads and
+// writes at both of their ends fail.
+func (l *Loopback) Disconnect() {
+	l.mu.Lock()
+	streams := make([]*loopbackStream, 0, len(l.streams))
+	for s := range l.streams {
+		streams = append(streams, s)
+	}
+	l.mu.Unlock()
+	for _, s := range streams {
+		s.in.disconnect()
+		s.out.disconnect()
+	}
+}
+
+// Outstanding returns the number of streams and listeners that were created
+// but not yet released, i.e., listeners that were neither closed nor
+// garbage-collected, and streams whose Go connections weren't yet
+// garbage-collected.
+func (l *Loopback) Outstanding() int {
+	return int(atomic.LoadInt32(&l.outstanding))
+}
+
+func (l *Loopback) dial(ctx *context.T, address string, timeout time.Duration) (streamImpl, error) {
+	time.Sleep(l.opts.DialLatency)
+	l.mu.Lock()
+	ln := l.listeners[address]
+	l.nextID++
+	id := l.nextID
+	l.mu.Unlock()
+	if ln == nil {
+		return nil, fmt.Errorf("dial %s: %v", address, errConnectionRefused)
+	}
+	a2b, b2a := l.newPipe(), l.newPipe()
+	clientAddr := fmt.Sprintf("%s#%d", address, id)
+	client := l.newStream(clientAddr, address, b2a, a2b)
+	server := l.newStream(address, clientAddr, a2b, b2a)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case ln.accepted <- server:
+		return client, nil
+	case <-ln.closed:
+		err = fmt.Errorf("dial %s: %v", address, errConnectionRefused)
+	case <-expired:
+		err = deadlineError{}
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	client.close()
+	client.release()
+	server.close()
+	server.release()
+	return nil, err
+}
+
+func (l *Loopback) resolve(ctx *context.T, address string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resolves++
+	if addrs, ok := l.aliases[address]; ok {
+		return append([]string(nil), addrs...), nil
+	}
+	return []string{address}, nil
+}
+
+func (l *Loopback) listen(ctx *context.T, address string) (listenerImpl, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if address == "" {
+		l.nextID++
+		address = fmt.Sprintf("loopback-%d", l.nextID)
+	}
+	if l.listeners[address] != nil {
+		return nil, fmt.Errorf("listen %s: %v", address, errAddressInUse)
+	}
+	ln := &loopbackListener{
+		protocol: l,
+		addr:     address,
+		accepted: make(chan *loopbackStream, loopbackBacklog),
+		closed:   make(chan struct{}),
+	}
+	l.listeners[address] = ln
+	atomic.AddInt32(&l.outstanding, 1)
+	return ln, nil
+}
+
+func (l *Loopback) release() {}
+
+func (l *Loopback) newPipe() *loopbackPipe {
+	return &loopbackPipe{protocol: l, changed: make(chan struct{}, 1)}
+}
+
+func (l *Loopback) newStream(addr, remote string, in, out *loopbackPipe) *loopbackStream {
+	s := &loopbackStream{protocol: l, addr: addr, remote: remote, in: in, out: out}
+	l.mu.Lock()
+	l.streams[s] = true
+	l.mu.Unlock()
+	atomic.AddInt32(&l.outstanding, 1)
+	return s
+}
+
+// lost reports whether the next packet is lost.
+func (l *Loopback) lost() bool {
+	if l.opts.LossRate <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rand.Float64() < l.opts.LossRate
+}
+
+type loopbackListener struct {
+	protocol  *Loopback
+	addr      string
+	accepted  chan *loopbackStream
+	closeOnce sync.Once
+	closed    chan struct{}
+	released  int32
+}
+
+func (l *loopbackListener) accept() (streamImpl, error) {
+	select {
+	case s := <-l.accepted:
+		return s, nil
+	case <-l.closed:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *loopbackListener) address() string {
+	return l.addr
+}
+
+func (l *loopbackListener) close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		l.protocol.mu.Lock()
+		if l.protocol.listeners[l.addr] == l {
+			delete(l.protocol.listeners, l.addr)
+		}
+		l.protocol.mu.Unlock()
+	})
+	l.drain()
+	return nil
+}
+
+// drain refuses the dialed streams that were not accepted.
+func (l *loopbackListener) drain() {
+	for {
+		select {
+		case s := <-l.accepted:
+			s.release()
+		default:
+			return
+		}
+	}
+}
+
+func (l *loopbackListener) release() {
+	if atomic.CompareAndSwapInt32(&l.released, 0, 1) {
+		l.close()
+		atomic.AddInt32(&l.protocol.outstanding, -1)
+	}
+}
+
+// loopbackStream is one end of a loopback connection.  It reads from one pipe
+// and writes to the other.
+type loopbackStream struct {
+	protocol *Loopback
+	addr     string
+	remote   string
+	in, out  *loopbackPipe
+	released int32
+}
+
+func (s *loopbackStream) read(max int) ([]byte, error) {
+	return s.in.read(max)
+}
+
+func (s *loopbackStream) write(b []byte) error {
+	return s.out.write(b)
+}
+
+// close closes the stream: the other end reads the data written so far
+// followed by io.EOF, and its writes fail.
+func (s *loopbackStream) close() error {
+	s.out.closeWriter()
+	s.in.closeReader()
+	return nil
+}
+
+func (s *loopbackStream) localAddress() string {
+	return s.addr
+}
+
+func (s *loopbackStream) remoteAddress() string {
+	return s.remote
+}
+
+func (s *loopbackStream) release() {
+	if atomic.CompareAndSwapInt32(&s.released, 0, 1) {
+		s.close()
+		s.protocol.mu.Lock()
+		delete(s.protocol.streams, s)
+		s.protocol.mu.Unlock()
+		atomic.AddInt32(&s.protocol.outstanding, -1)
+	}
+}
+
+type packet struct {
+	data []byte
+	due  time.Time // when the packet becomes readable
+}
+
+// loopbackPipe carries packets in one direction of a loopback connection.
+type loopbackPipe struct {
+	protocol *Loopback
+	changed  chan struct{} // signaled whenever the state below changes
+
+	mu           sync.Mutex
+	packets      []packet
+	writerClosed bool
+	readerClosed bool
+	disconnected bool
+}
+
+func (p *loopbackPipe) write(b []byte) error {
+	mtu := p.protocol.opts.MTU
+	if mtu <= 0 {
+		mtu = len(b)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.disconnected:
+		return errDisconnected
+	case p.writerClosed:
+		return errStreamClosed
+	case p.readerClosed:
+		return errConnectionReset
+	}
+	due := time.Now().Add(p.protocol.opts.Latency)
+	for len(b) > 0 {
+		n := mtu
+		if n > len(b) {
+			n = len(b)
+		}
+		if !p.protocol.lost() {
+			data := make([]byte, n)
+			copy(data, b)
+			p.packets = append(p.packets, packet{data, due})
+		}
+		b = b[n:]
+	}
+	signal(p.changed)
+	return nil
+}
+
+func (p *loopbackPipe) read(max int) ([]byte, error) {
+	for {
+		p.mu.Lock()
+		switch {
+		case p.disconnected:
+			p.mu.Unlock()
+			return nil, errDisconnected
+		case p.readerClosed:
+			p.mu.Unlock()
+			return nil, errStreamClosed
+		}
+		var wait time.Duration
+		if len(p.packets) > 0 {
+			head := &p.packets[0]
+			if wait = head.due.Sub(time.Now()); wait <= 0 {
+				data := head.data
+				if len(data) > max {
+					data = data[:max]
+				}
+				if head.data = head.data[len(data):]; len(head.data) == 0 {
+					p.packets = p.packets[1:]
+				}
+				p.mu.Unlock()
+				return data, nil
+			}
+		} else if p.writerClosed {
+			p.mu.Unlock()
+			return nil, io.EOF
+		}
+		p.mu.Unlock()
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-p.changed:
+			}
+			timer.Stop()
+		} else {
+			<-p.changed
+		}
+	}
+}
+
+func (p *loopbackPipe) closeWriter() {
+	p.mu.Lock()
+	p.writerClosed = true
+	p.mu.Unlock()
+	signal(p.changed)
+}
+
+func (p *loopbackPipe) closeReader() {
+	p.mu.Lock()
+	p.readerClosed = true
+	p.packets = nil
+	p.mu.Unlock()
+	signal(p.changed)
+}
+
+func (p *loopbackPipe) disconnect() {
+	p.mu.Lock()
+	p.disconnected = true
+	p.packets = nil
+	p.mu.Unlock()
+	signal(p.changed)
+}
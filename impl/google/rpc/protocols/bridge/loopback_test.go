@@ -0,0 +1,248 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/flow"
+)
+
+// connect listens on a new address of the provided protocol and returns both
+// ends of a connection dialed to it.
+func connect(t *testing.T, ctx *context.T, p flow.Protocol, protocol string) (dialed, accepted flow.Conn, ln flow.Listener) {
+	ln, err := p.Listen(ctx, protocol, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dialed, err = p.Dial(ctx, protocol, ln.Addr().String(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if accepted, err = ln.Accept(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return dialed, accepted, ln
+}
+
+func TestLoopbackMessages(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	const latency = 20 * time.Millisecond
+	RegisterLoopback("sim-bt", LoopbackOptions{Latency: latency, MTU: 20})
+	p, _ := flow.RegisteredProtocol("sim-bt")
+	if p == nil {
+		t.Fatal("protocol sim-bt wasn't registered")
+	}
+	dialed, accepted, ln := connect(t, ctx, p, "sim-bt")
+	defer ln.Close()
+	if got, want := ln.Addr().Network(), "sim-bt"; got != want {
+		t.Errorf("got listener network %q, want %q", got, want)
+	}
+	if got, want := accepted.LocalAddr().String(), ln.Addr().String(); got != want {
+		t.Errorf("got accepted address %q, want %q", got, want)
+	}
+	if _, addrs, err := p.Resolve(ctx, "sim-bt", "a"); err != nil || len(addrs) != 1 || addrs[0] != "a" {
+		t.Errorf("got addresses %v, error %v, want [a]", addrs, err)
+	}
+
+	// Messages larger than the MTU are split into several packets and
+	// reassembled.
+	msgs := [][]byte{[]byte("hi"), bytes.Repeat([]byte("0123456789"), 10), {}, bytes.Repeat([]byte{'x'}, 1000)}
+	start := time.Now()
+	go func() {
+		for _, msg := range msgs {
+			dialed.WriteMsg(msg[:len(msg)/2], msg[len(msg)/2:])
+		}
+	}()
+	for _, want := range msgs {
+		got, err := accepted.ReadMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("got message %q, want %q", got, want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < latency {
+		t.Errorf("messages arrived after %v, before the latency of %v", elapsed, latency)
+	}
+
+	// The other direction works too.
+	if _, err := accepted.WriteMsg([]byte("back")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := dialed.ReadMsg(); err != nil || string(got) != "back" {
+		t.Errorf("got message %q, error %v, want %q", got, err, "back")
+	}
+
+	// Closing a connection ends the stream of messages at the other end.
+	if _, err := dialed.WriteMsg([]byte("last")); err != nil {
+		t.Fatal(err)
+	}
+	dialed.Close()
+	if got, err := accepted.ReadMsg(); err != nil || string(got) != "last" {
+		t.Errorf("got message %q, error %v, want %q", got, err, "last")
+	}
+	if _, err := accepted.ReadMsg(); err != io.EOF {
+		t.Errorf("got error %v, want %v", err, io.EOF)
+	}
+	if _, err := accepted.WriteMsg([]byte("late")); err == nil {
+		t.Errorf("write to a closed connection succeeded")
+	}
+}
+
+func TestLoopbackMTU(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{MTU: 4})
+	ln, err := l.listen(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := l.dial(ctx, ln.address(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ln.accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.write([]byte("abcdefghij"))
+	for _, want := range []string{"abc", "d", "efgh", "ij"} {
+		max := 3
+		if want != "abc" {
+			max = 100
+		}
+		if got, err := b.read(max); err != nil || string(got) != want {
+			t.Errorf("got %q, error %v, want %q", got, err, want)
+		}
+	}
+}
+
+func TestLoopbackDisconnect(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{})
+	p := newProtocolAdapter("sim", l)
+	dialed, accepted, ln := connect(t, ctx, p, "sim")
+	defer ln.Close()
+	errs := make(chan error)
+	go func() {
+		_, err := accepted.ReadMsg()
+		errs <- err
+	}()
+	l.Disconnect()
+	if err := <-errs; err != errDisconnected {
+		t.Errorf("got pending read error %v, want %v", err, errDisconnected)
+	}
+	if _, err := dialed.WriteMsg([]byte("lost")); err != errDisconnected {
+		t.Errorf("got write error %v, want %v", err, errDisconnected)
+	}
+	if _, err := dialed.ReadMsg(); err != errDisconnected {
+		t.Errorf("got read error %v, want %v", err, errDisconnected)
+	}
+
+	// New connections are unaffected.
+	dialed, accepted, _ = connect(t, ctx, p, "sim")
+	if _, err := dialed.WriteMsg([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := accepted.ReadMsg(); err != nil || string(got) != "new" {
+		t.Errorf("got message %q, error %v, want %q", got, err, "new")
+	}
+}
+
+func TestLoopbackLoss(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	p := newProtocolAdapter("sim", NewLoopback(LoopbackOptions{LossRate: 1}))
+	dialed, accepted, ln := connect(t, ctx, p, "sim")
+	defer ln.Close()
+	if _, err := dialed.WriteMsg([]byte("lost")); err != nil {
+		t.Fatal(err)
+	}
+	accepted.(*conn).SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err := accepted.ReadMsg()
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("got error %v, want a timeout", err)
+	}
+}
+
+func TestLoopbackDialErrors(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	p := newProtocolAdapter("sim", NewLoopback(LoopbackOptions{}))
+	if _, err := p.Dial(ctx, "sim", "nowhere", 0); err == nil {
+		t.Errorf("dial to an unknown address succeeded")
+	}
+	ln, err := p.Listen(ctx, "sim", "here")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Listen(ctx, "sim", "here"); err == nil {
+		t.Errorf("listen on an address in use succeeded")
+	}
+	// Fill up the backlog of the listener, so that dials block.
+	for i := 0; i < loopbackBacklog; i++ {
+		if _, err := p.Dial(ctx, "sim", "here", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Dial(ctx, "sim", "here", 10*time.Millisecond); err != (deadlineError{}) {
+		t.Errorf("got error %v, want %v", err, deadlineError{})
+	}
+	cctx, ccancel := context.WithCancel(ctx)
+	ccancel()
+	if _, err := p.Dial(cctx, "sim", "here", 0); err == nil {
+		t.Errorf("dial with a canceled context succeeded")
+	}
+
+	ln.Close()
+	if _, err := ln.Accept(ctx); err != errListenerClosed {
+		t.Errorf("got error %v, want %v", err, errListenerClosed)
+	}
+	if _, err := p.Dial(ctx, "sim", "here", 0); err == nil {
+		t.Errorf("dial to a closed listener succeeded")
+	}
+	// The address can be reused.
+	ln, err = p.Listen(ctx, "sim", "here")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+}
+
+func TestLoopbackFinalizers(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{})
+	p := newProtocolAdapter("sim", l)
+	func() {
+		dialed, accepted, ln := connect(t, ctx, p, "sim")
+		dialed.WriteMsg([]byte("msg"))
+		accepted.ReadMsg()
+		dialed.Close()
+		accepted.Close()
+		ln.Close()
+	}()
+	// Closed listeners are released right away.
+	if got, want := l.Outstanding(), 2; got != want {
+		t.Errorf("got %d outstanding streams before GC, want %d", got, want)
+	}
+	// Streams are released once their Go counterparts are garbage-collected.
+	for deadline := time.Now().Add(5 * time.Second); l.Outstanding() > 0 && time.Now().Before(deadline); {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := l.Outstanding(); got != 0 {
+		t.Errorf("got %d outstanding streams after GC, want 0", got)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build java android
+
+// Package bridge registers Java-implemented transport protocols with the
+// Vanadium flow layer.
+//
+// A Java protocol is an object implementing the
+// io.v.impl.google.rpc.protocols.Protocol interface, whose dial, resolve and
+// accept methods, as well as the read and write methods of its streams,
+// report their results through a Callback.  Its resolve method maps
+// human-friendly names (e.g., device aliases, names of paired devices or
+// discovery advertisement ids) to addresses; resolutions are cached by Go,
+// see SetResolveTTL.  Java classes that implement a protocol with static
+// methods instead (e.g., bt and ble) are registered with RegisterStatic.
+package bridge
+
+import (
+	"fmt"
+	"time"
+
+	"v.io/v23/context"
+
+	jutil "v.io/x/jni/util"
+	jcontext "v.io/x/jni/v23/context"
+)
+
+var (
+	contextSign  = jutil.ClassSign("io.v.v23.context.VContext")
+	listenerSign = jutil.ClassSign("io.v.impl.google.rpc.protocols.Protocol$Listener")
+)
+
+// Register registers the provided Java Protocol object with the flow layer
+// under the given protocol name, replacing any protocol previously registered
+// under that name.
+func Register(env jutil.Env, name string, jProtocol jutil.Object) error {
+	if name == "" {
+		return fmt.Errorf("Java protocols must have a non-empty name")
+	}
+	if jProtocol.IsNull() {
+		return fmt.Errorf("Java protocol %q cannot be null", name)
+	}
+	// Reference Java Protocol; it will be de-referenced when the protocol is
+	// released, i.e., once it is replaced and no longer in use.
+	registerProtocol(name, &javaProtocol{jutil.NewGlobalRef(env, jProtocol)})
+	return nil
+}
+
+// RegisterClass instantiates the Java class with the provided name, which
+// must implement Protocol and have a no-argument constructor, and registers
+// the instance under the given protocol name.
+func RegisterClass(env jutil.Env, name, className string) error {
+	class, err := jutil.JFindClass(env, className)
+	if err != nil {
+		return err
+	}
+	jProtocol, err := jutil.NewObject(env, class, nil)
+	if err != nil {
+		return err
+	}
+	return Register(env, name, jProtocol)
+}
+
+func deleteGlobalRef(obj jutil.Object) {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jutil.DeleteGlobalRef(env, obj)
+}
+
+// javaProtocol is a protocolImpl backed by a Java Protocol object.
+type javaProtocol struct {
+	jProtocol jutil.Object
+}
+
+func (p *javaProtocol) dial(ctx *context.T, address string, timeout time.Duration) (streamImpl, error) {
+	env, freeFunc := jutil.GetEnv()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		freeFunc()
+		return nil, err
+	}
+	// This method will invoke the freeFunc().
+	jStream, err := jutil.CallCallbackMethod(env, freeFunc, p.jProtocol, "dial", []jutil.Sign{contextSign, jutil.StringSign, jutil.DurationSign}, jContext, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &javaStream{jStream}, nil
+}
+
+func (p *javaProtocol) resolve(ctx *context.T, address string) ([]string, error) {
+	env, freeFunc := jutil.GetEnv()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		freeFunc()
+		return nil, err
+	}
+	// This method will invoke the freeFunc().
+	jAddrs, err := jutil.CallCallbackMethod(env, freeFunc, p.jProtocol, "resolve", []jutil.Sign{contextSign, jutil.StringSign}, jContext, address)
+	if err != nil {
+		return nil, err
+	}
+	env, freeFunc = jutil.GetEnv()
+	defer freeFunc()
+	defer jutil.DeleteGlobalRef(env, jAddrs)
+	return jutil.GoStringArray(env, jAddrs)
+}
+
+func (p *javaProtocol) listen(ctx *context.T, address string) (listenerImpl, error) {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	jListener, err := jutil.CallObjectMethod(env, p.jProtocol, "listen", []jutil.Sign{contextSign, jutil.StringSign}, listenerSign, jContext, address)
+	if err != nil {
+		return nil, err
+	}
+	// Reference Java Listener; it will be de-referenced when the listener is
+	// released.
+	return &javaListener{jutil.NewGlobalRef(env, jListener)}, nil
+}
+
+func (p *javaProtocol) release() {
+	deleteGlobalRef(p.jProtocol)
+}
+
+// javaListener is a listenerImpl backed by a Java Protocol$Listener object.
+type javaListener struct {
+	jListener jutil.Object
+}
+
+func (l *javaListener) accept() (streamImpl, error) {
+	env, freeFunc := jutil.GetEnv()
+	// This method will invoke the freeFunc().
+	jStream, err := jutil.CallCallbackMethod(env, freeFunc, l.jListener, "accept", nil)
+	if err != nil {
+		return nil, err
+	}
+	return &javaStream{jStream}, nil
+}
+
+func (l *javaListener) address() string {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	addr, err := jutil.CallStringMethod(env, l.jListener, "address", nil)
+	if err != nil {
+		return ""
+	}
+	return addr
+}
+
+func (l *javaListener) close() error {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	return jutil.CallVoidMethod(env, l.jListener, "close", nil)
+}
+
+func (l *javaListener) release() {
+	deleteGlobalRef(l.jListener)
+}
+
+// javaStream is a streamImpl backed by a Java Protocol$Stream object.  The
+// Java object is assumed to hold a global reference.
+type javaStream struct {
+	jStream jutil.Object
+}
+
+func (s *javaStream) read(max int) ([]byte, error) {
+	env, freeFunc := jutil.GetEnv()
+	// This method will invoke the freeFunc().
+	jResult, err := jutil.CallCallbackMethod(env, freeFunc, s.jStream, "read", []jutil.Sign{jutil.IntSign}, max)
+	if err != nil {
+		return nil, err
+	}
+	env, freeFunc = jutil.GetEnv()
+	defer freeFunc()
+	defer jutil.DeleteGlobalRef(env, jResult)
+	return jutil.GoByteArray(env, jResult), nil
+}
+
+func (s *javaStream) write(b []byte) error {
+	env, freeFunc := jutil.GetEnv()
+	// This method will invoke the freeFunc().
+	_, err := jutil.CallCallbackMethod(env, freeFunc, s.jStream, "write", []jutil.Sign{jutil.ByteArraySign}, b)
+	return err
+}
+
+func (s *javaStream) close() error {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	return jutil.CallVoidMethod(env, s.jStream, "close", nil)
+}
+
+func (s *javaStream) localAddress() string {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	addr, _ := jutil.CallStringMethod(env, s.jStream, "localAddress", nil)
+	return addr
+}
+
+func (s *javaStream) remoteAddress() string {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	addr, _ := jutil.CallStringMethod(env, s.jStream, "remoteAddress", nil)
+	return addr
+}
+
+func (s *javaStream) release() {
+	deleteGlobalRef(s.jStream)
+}
@@ -0,0 +1,123 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultResolveTTL is the default time for which the addresses a name
+	// resolves to are cached.
+	defaultResolveTTL = time.Minute
+	// maxResolveEntries is the maximum number of names whose resolution is
+	// cached for a protocol.
+	maxResolveEntries = 256
+)
+
+var adapters = struct {
+	sync.Mutex
+	byName map[string]*protocolAdapter
+}{byName: make(map[string]*protocolAdapter)}
+
+// SetResolveTTL sets the time for which the addresses that names resolve to
+// through the protocol registered under the provided name are cached, and
+// drops the cached resolutions.  A zero TTL disables caching.
+func SetResolveTTL(protocol string, ttl time.Duration) error {
+	adapters.Lock()
+	p := adapters.byName[protocol]
+	adapters.Unlock()
+	if p == nil {
+		return fmt.Errorf("protocol %q isn't a registered Java protocol", protocol)
+	}
+	p.cache.setTTL(ttl)
+	return nil
+}
+
+// resolveCache caches the addresses that names resolve to.  Names resolve
+// to addresses (e.g., MAC addresses) through the protocol implementation,
+// which may be expensive, e.g., require discovery.
+type resolveCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]resolveEntry
+}
+
+type resolveEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newResolveCache(ttl time.Duration) *resolveCache {
+	return &resolveCache{ttl: ttl, entries: make(map[string]resolveEntry)}
+}
+
+// setTTL sets the TTL of future resolutions, dropping the cached ones.
+func (c *resolveCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+	c.entries = make(map[string]resolveEntry)
+}
+
+// lookup returns the unexpired addresses the provided name resolves to, if
+// any.
+func (c *resolveCache) lookup(name string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(e.expires) {
+		delete(c.entries, name)
+		return nil, false
+	}
+	return append([]string(nil), e.addrs...), true
+}
+
+// insert caches the addresses the provided name resolves to.
+func (c *resolveCache) insert(name string, addrs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl <= 0 || len(addrs) == 0 {
+		return
+	}
+	now := time.Now()
+	if _, ok := c.entries[name]; !ok && len(c.entries) >= maxResolveEntries {
+		// Make room, dropping the expired entries or, if there are none,
+		// the entry closest to expiring.
+		var victim string
+		var victimExpires time.Time
+		for n, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, n)
+			} else if victim == "" || e.expires.Before(victimExpires) {
+				victim, victimExpires = n, e.expires
+			}
+		}
+		if len(c.entries) >= maxResolveEntries {
+			delete(c.entries, victim)
+		}
+	}
+	c.entries[name] = resolveEntry{append([]string(nil), addrs...), now.Add(c.ttl)}
+}
+
+// invalidate drops the cached resolutions that include the provided address,
+// e.g., because dialing it failed.
+func (c *resolveCache) invalidate(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n, e := range c.entries {
+		for _, a := range e.addrs {
+			if a == addr {
+				delete(c.entries, n)
+				break
+			}
+		}
+	}
+}
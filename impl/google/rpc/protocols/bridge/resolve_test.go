@@ -0,0 +1,110 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/flow"
+)
+
+func (l *Loopback) resolveCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.resolves
+}
+
+func TestResolveCache(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := RegisterLoopback("sim-resolve", LoopbackOptions{})
+	p, _ := flow.RegisteredProtocol("sim-resolve")
+	ln, err := p.Listen(ctx, "sim-resolve", "00:11:22:33:44:55")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l.Alias("phone", "00:11:22:33:44:55", "66:77:88:99:aa:bb")
+
+	want := []string{"00:11:22:33:44:55", "66:77:88:99:aa:bb"}
+	for i := 0; i < 3; i++ {
+		protocol, addrs, err := p.Resolve(ctx, "sim-resolve", "phone")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if protocol != "sim-resolve" || !reflect.DeepEqual(addrs, want) {
+			t.Errorf("got %s %v, want sim-resolve %v", protocol, addrs, want)
+		}
+		// Modifying the result doesn't modify the cache.
+		addrs[0] = "modified"
+	}
+	if got := l.resolveCount(); got != 1 {
+		t.Errorf("got %d resolutions by the protocol, want 1", got)
+	}
+
+	// Failing to dial an address drops the resolutions that include it.
+	if _, err := p.Dial(ctx, "sim-resolve", "66:77:88:99:aa:bb", 0); err == nil {
+		t.Fatal("dial to an unknown address succeeded")
+	}
+	l.Alias("phone", "00:11:22:33:44:55")
+	if _, addrs, _ := p.Resolve(ctx, "sim-resolve", "phone"); !reflect.DeepEqual(addrs, want[:1]) {
+		t.Errorf("got %v, want %v", addrs, want[:1])
+	}
+	if got := l.resolveCount(); got != 2 {
+		t.Errorf("got %d resolutions by the protocol, want 2", got)
+	}
+
+	// Resolutions expire.
+	if err := SetResolveTTL("sim-resolve", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	p.Resolve(ctx, "sim-resolve", "phone")
+	p.Resolve(ctx, "sim-resolve", "phone")
+	if got := l.resolveCount(); got != 3 {
+		t.Errorf("got %d resolutions by the protocol, want 3", got)
+	}
+	time.Sleep(20 * time.Millisecond)
+	p.Resolve(ctx, "sim-resolve", "phone")
+	if got := l.resolveCount(); got != 4 {
+		t.Errorf("got %d resolutions by the protocol, want 4", got)
+	}
+
+	// A zero TTL disables caching.
+	SetResolveTTL("sim-resolve", 0)
+	p.Resolve(ctx, "sim-resolve", "phone")
+	p.Resolve(ctx, "sim-resolve", "phone")
+	if got := l.resolveCount(); got != 6 {
+		t.Errorf("got %d resolutions by the protocol, want 6", got)
+	}
+
+	if err := SetResolveTTL("unknown", time.Second); err == nil {
+		t.Errorf("setting the TTL of an unknown protocol succeeded")
+	}
+}
+
+func TestResolveCacheEviction(t *testing.T) {
+	c := newResolveCache(time.Hour)
+	for i := 0; i <= maxResolveEntries; i++ {
+		c.insert(fmt.Sprint(i), []string{"addr"})
+	}
+	if got := len(c.entries); got != maxResolveEntries {
+		t.Errorf("got %d entries, want %d", got, maxResolveEntries)
+	}
+	// The entry closest to expiring was evicted.
+	if _, ok := c.lookup("0"); ok {
+		t.Errorf("oldest entry wasn't evicted")
+	}
+	if _, ok := c.lookup(fmt.Sprint(maxResolveEntries)); !ok {
+		t.Errorf("newest entry isn't cached")
+	}
+	c.invalidate("addr")
+	if got := len(c.entries); got != 0 {
+		t.Errorf("got %d entries after invalidating their address, want 0", got)
+	}
+}
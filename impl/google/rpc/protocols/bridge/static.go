@@ -0,0 +1,152 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build java android
+
+package bridge
+
+import (
+	"strings"
+	"time"
+
+	"v.io/v23/context"
+
+	jutil "v.io/x/jni/util"
+	jcontext "v.io/x/jni/v23/context"
+)
+
+// RegisterStatic registers the Java class with the provided name under the
+// given protocol name.  Unlike Protocol objects, such a class implements the
+// protocol with static dial and listen methods, and doesn't resolve names:
+// addresses resolve to themselves.  If callbacks is true, dials, accepts and
+// the reads and writes of streams report their results through a Callback;
+// otherwise, they return them.  This is the contract of the bt and ble
+// classes, which predate the Protocol interface.
+func RegisterStatic(env jutil.Env, name, className string, callbacks bool) error {
+	class, err := jutil.JFindClass(env, className)
+	if err != nil {
+		return err
+	}
+	javaName := strings.Replace(className, "/", ".", -1)
+	registerProtocol(name, &staticProtocol{
+		class:        class,
+		streamSign:   jutil.ClassSign(javaName + "$Stream"),
+		listenerSign: jutil.ClassSign(javaName + "$Listener"),
+		callbacks:    callbacks,
+	})
+	return nil
+}
+
+// staticProtocol is a protocolImpl backed by the static methods of a Java
+// class.
+type staticProtocol struct {
+	class                    jutil.Class
+	streamSign, listenerSign jutil.Sign
+	callbacks                bool
+}
+
+func (p *staticProtocol) dial(ctx *context.T, address string, timeout time.Duration) (streamImpl, error) {
+	env, freeFunc := jutil.GetEnv()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		freeFunc()
+		return nil, err
+	}
+	argSigns := []jutil.Sign{contextSign, jutil.StringSign, jutil.DurationSign}
+	if p.callbacks {
+		// This method will invoke the freeFunc().
+		jStream, err := jutil.CallStaticCallbackMethod(env, freeFunc, p.class, "dial", argSigns, jContext, address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &staticStream{&javaStream{jStream}, true}, nil
+	}
+	defer freeFunc()
+	jStream, err := jutil.CallStaticObjectMethod(env, p.class, "dial", argSigns, p.streamSign, jContext, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// Reference Java Stream; it will be de-referenced when the stream is
+	// released.
+	return &staticStream{&javaStream{jutil.NewGlobalRef(env, jStream)}, false}, nil
+}
+
+func (p *staticProtocol) resolve(ctx *context.T, address string) ([]string, error) {
+	return []string{address}, nil
+}
+
+func (p *staticProtocol) listen(ctx *context.T, address string) (listenerImpl, error) {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	jListener, err := jutil.CallStaticObjectMethod(env, p.class, "listen", []jutil.Sign{contextSign, jutil.StringSign}, p.listenerSign, jContext, address)
+	if err != nil {
+		return nil, err
+	}
+	// Reference Java Listener; it will be de-referenced when the listener is
+	// released.
+	return &staticListener{&javaListener{jutil.NewGlobalRef(env, jListener)}, p}, nil
+}
+
+// release is a no-op: the class reference is kept for the lifetime of the
+// process.
+func (p *staticProtocol) release() {}
+
+// staticListener is a listenerImpl backed by the Listener of a static Java
+// protocol class.
+type staticListener struct {
+	*javaListener
+	protocol *staticProtocol
+}
+
+func (l *staticListener) accept() (streamImpl, error) {
+	if l.protocol.callbacks {
+		s, err := l.javaListener.accept()
+		if err != nil {
+			return nil, err
+		}
+		return &staticStream{s.(*javaStream), true}, nil
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jStream, err := jutil.CallObjectMethod(env, l.jListener, "accept", nil, l.protocol.streamSign)
+	if err != nil {
+		return nil, err
+	}
+	// Reference Java Stream; it will be de-referenced when the stream is
+	// released.
+	return &staticStream{&javaStream{jutil.NewGlobalRef(env, jStream)}, false}, nil
+}
+
+// staticStream is a streamImpl backed by the Stream of a static Java protocol
+// class, which doesn't report its remote address.
+type staticStream struct {
+	*javaStream
+	callbacks bool
+}
+
+func (s *staticStream) read(max int) ([]byte, error) {
+	if s.callbacks {
+		return s.javaStream.read(max)
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	return jutil.CallByteArrayMethod(env, s.jStream, "read", []jutil.Sign{jutil.IntSign}, max)
+}
+
+func (s *staticStream) write(b []byte) error {
+	if s.callbacks {
+		return s.javaStream.write(b)
+	}
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	return jutil.CallVoidMethod(env, s.jStream, "write", []jutil.Sign{jutil.ByteArraySign}, b)
+}
+
+func (s *staticStream) remoteAddress() string {
+	return ""
+}
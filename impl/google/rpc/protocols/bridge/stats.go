@@ -0,0 +1,256 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"v.io/v23/naming"
+	"v.io/x/ref/lib/stats"
+	"v.io/x/ref/lib/stats/counter"
+)
+
+// StatsPrefix is the prefix of the stats exported for the connections of
+// Java-backed protocols.  For each protocol, the counters named
+// <prefix>/<protocol>/{opened,closed,bytes-read,bytes-written,frames-read,
+// frames-written,read-errors,write-errors} aggregate the statistics of all of
+// its connections, and <prefix>/<protocol>/conns/<id> describes each of its
+// active connections.
+const StatsPrefix = "system/jni/protocols"
+
+// ConnStats is a snapshot of the statistics of a connection.
+type ConnStats struct {
+	// ID identifies the connection among all connections of Java-backed
+	// protocols.
+	ID                    uint64
+	Protocol              string
+	LocalAddr, RemoteAddr string
+	// Created is the time the connection was established.
+	Created time.Time
+	// Lifetime is the time elapsed since the connection was established,
+	// until it was closed or the snapshot was taken.
+	Lifetime time.Duration
+	// BytesRead and BytesWritten count the bytes transferred over the
+	// stream, including frame headers.
+	BytesRead, BytesWritten int64
+	// FramesRead and FramesWritten count the messages transferred.
+	FramesRead, FramesWritten int64
+	// ReadErrors and WriteErrors count the failed message reads and writes.
+	ReadErrors, WriteErrors int64
+	// ReadLatency and WriteLatency are the average durations of the reads
+	// and writes of the stream, i.e., of the calls into the protocol
+	// implementation.  Reads include the time spent waiting for data.
+	ReadLatency, WriteLatency time.Duration
+	// LastError is the last error returned by a message read or write, if
+	// any.
+	LastError error
+}
+
+func (s ConnStats) String() string {
+	str := fmt.Sprintf("%s %s->%s up %v: read %d bytes in %d frames (%d errors, %v latency), wrote %d bytes in %d frames (%d errors, %v latency)",
+		s.Protocol, s.LocalAddr, s.RemoteAddr, s.Lifetime,
+		s.BytesRead, s.FramesRead, s.ReadErrors, s.ReadLatency,
+		s.BytesWritten, s.FramesWritten, s.WriteErrors, s.WriteLatency)
+	if s.LastError != nil {
+		str += fmt.Sprintf(", last error: %v", s.LastError)
+	}
+	return str
+}
+
+// Connections returns the statistics of the active connections of all
+// Java-backed protocols, ordered by ID.
+func Connections() []ConnStats {
+	registry.mu.Lock()
+	active := make([]*connStats, 0, len(registry.active))
+	for _, s := range registry.active {
+		active = append(active, s)
+	}
+	registry.mu.Unlock()
+	ret := make([]ConnStats, len(active))
+	for i, s := range active {
+		ret[i] = s.snapshot()
+	}
+	sort.Sort(connStatsByID(ret))
+	return ret
+}
+
+type connStatsByID []ConnStats
+
+func (s connStatsByID) Len() int           { return len(s) }
+func (s connStatsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s connStatsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+var registry = struct {
+	mu        sync.Mutex
+	nextID    uint64
+	active    map[uint64]*connStats
+	protocols map[string]*protocolCounters
+}{
+	active:    make(map[uint64]*connStats),
+	protocols: make(map[string]*protocolCounters),
+}
+
+// protocolCounters aggregate the statistics of the connections of a protocol.
+type protocolCounters struct {
+	opened, closed                         *counter.Counter
+	bytesRead, bytesWritten, framesRead    *counter.Counter
+	framesWritten, readErrors, writeErrors *counter.Counter
+}
+
+func protocolCountersLocked(protocol string) *protocolCounters {
+	if c := registry.protocols[protocol]; c != nil {
+		return c
+	}
+	name := func(stat string) string { return naming.Join(StatsPrefix, protocol, stat) }
+	c := &protocolCounters{
+		opened:        stats.NewCounter(name("opened")),
+		closed:        stats.NewCounter(name("closed")),
+		bytesRead:     stats.NewCounter(name("bytes-read")),
+		bytesWritten:  stats.NewCounter(name("bytes-written")),
+		framesRead:    stats.NewCounter(name("frames-read")),
+		framesWritten: stats.NewCounter(name("frames-written")),
+		readErrors:    stats.NewCounter(name("read-errors")),
+		writeErrors:   stats.NewCounter(name("write-errors")),
+	}
+	registry.protocols[protocol] = c
+	return c
+}
+
+// connStats tracks the statistics of a connection.
+type connStats struct {
+	id       uint64
+	name     string // name of the stat describing the connection
+	counters *protocolCounters
+
+	mu         sync.Mutex
+	stats      ConnStats
+	reads      int64
+	writes     int64
+	readTime   time.Duration
+	writeTime  time.Duration
+	closed     time.Time
+	registered bool
+}
+
+// newConnStats creates the statistics of a new connection and registers them
+// among the active connections.
+func newConnStats(protocol, localAddr, remoteAddr string) *connStats {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.nextID++
+	s := &connStats{
+		id:         registry.nextID,
+		counters:   protocolCountersLocked(protocol),
+		registered: true,
+		stats: ConnStats{
+			ID:         registry.nextID,
+			Protocol:   protocol,
+			LocalAddr:  localAddr,
+			RemoteAddr: remoteAddr,
+			Created:    time.Now(),
+		},
+	}
+	s.name = naming.Join(StatsPrefix, protocol, "conns", strconv.FormatUint(s.id, 10))
+	registry.active[s.id] = s
+	s.counters.opened.Incr(1)
+	stats.NewStringFunc(s.name, func() string { return s.snapshot().String() })
+	return s
+}
+
+// unregister removes the connection from the active connections.  It may be
+// invoked more than once.
+func (s *connStats) unregister() {
+	s.mu.Lock()
+	registered := s.registered
+	s.registered = false
+	if registered {
+		s.closed = time.Now()
+	}
+	s.mu.Unlock()
+	if !registered {
+		return
+	}
+	registry.mu.Lock()
+	delete(registry.active, s.id)
+	registry.mu.Unlock()
+	stats.Delete(s.name)
+	s.counters.closed.Incr(1)
+}
+
+func (s *connStats) snapshot() ConnStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := s.stats
+	if s.closed.IsZero() {
+		ret.Lifetime = time.Since(ret.Created)
+	} else {
+		ret.Lifetime = s.closed.Sub(ret.Created)
+	}
+	if s.reads > 0 {
+		ret.ReadLatency = s.readTime / time.Duration(s.reads)
+	}
+	if s.writes > 0 {
+		ret.WriteLatency = s.writeTime / time.Duration(s.writes)
+	}
+	return ret
+}
+
+// streamRead records a read of n bytes from the stream that took d.
+func (s *connStats) streamRead(n int, d time.Duration) {
+	s.mu.Lock()
+	s.stats.BytesRead += int64(n)
+	s.reads++
+	s.readTime += d
+	s.mu.Unlock()
+	s.counters.bytesRead.Incr(int64(n))
+}
+
+// streamWrite records a write of n bytes to the stream that took d.
+func (s *connStats) streamWrite(n int, d time.Duration) {
+	s.mu.Lock()
+	s.stats.BytesWritten += int64(n)
+	s.writes++
+	s.writeTime += d
+	s.mu.Unlock()
+	s.counters.bytesWritten.Incr(int64(n))
+}
+
+// frameRead records the outcome of a message read.
+func (s *connStats) frameRead(err error) {
+	s.mu.Lock()
+	if err != nil {
+		s.stats.ReadErrors++
+		s.stats.LastError = err
+	} else {
+		s.stats.FramesRead++
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.counters.readErrors.Incr(1)
+	} else {
+		s.counters.framesRead.Incr(1)
+	}
+}
+
+// frameWritten records the outcome of a message write.
+func (s *connStats) frameWritten(err error) {
+	s.mu.Lock()
+	if err != nil {
+		s.stats.WriteErrors++
+		s.stats.LastError = err
+	} else {
+		s.stats.FramesWritten++
+	}
+	s.mu.Unlock()
+	if err != nil {
+		s.counters.writeErrors.Incr(1)
+	} else {
+		s.counters.framesWritten.Incr(1)
+	}
+}
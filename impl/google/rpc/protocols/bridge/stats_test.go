@@ -0,0 +1,128 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bridge
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/x/ref/lib/stats"
+)
+
+// findConn returns the statistics of the active connection with the provided
+// local address.
+func findConn(local net.Addr) (ConnStats, bool) {
+	for _, s := range Connections() {
+		if s.Protocol == local.Network() && s.LocalAddr == local.String() {
+			return s, true
+		}
+	}
+	return ConnStats{}, false
+}
+
+func TestConnStats(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	l := NewLoopback(LoopbackOptions{})
+	p := newProtocolAdapter("sim-stats", l)
+	dialed, accepted, ln := connect(t, ctx, p, "sim-stats")
+	defer ln.Close()
+
+	for _, msg := range []string{"hello", "world!"} {
+		if _, err := dialed.WriteMsg([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := accepted.ReadMsg(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ds, ok := findConn(dialed.LocalAddr())
+	if !ok {
+		t.Fatalf("dialed connection isn't active")
+	}
+	as, ok := findConn(accepted.LocalAddr())
+	if !ok {
+		t.Fatalf("accepted connection isn't active")
+	}
+	if ds.Protocol != "sim-stats" || ds.RemoteAddr != as.LocalAddr || as.RemoteAddr != ds.LocalAddr {
+		t.Errorf("got connections %v and %v, want connected ends of sim-stats", ds, as)
+	}
+	// Each message is preceded by a 3-byte header.
+	const bytes = int64(3 + len("hello") + 3 + len("world!"))
+	if ds.FramesWritten != 2 || ds.BytesWritten != bytes || ds.WriteErrors != 0 {
+		t.Errorf("got %d frames, %d bytes and %d errors written, want 2, %d and 0", ds.FramesWritten, ds.BytesWritten, ds.WriteErrors, bytes)
+	}
+	if as.FramesRead != 2 || as.BytesRead != bytes || as.ReadErrors != 0 {
+		t.Errorf("got %d frames, %d bytes and %d errors read, want 2, %d and 0", as.FramesRead, as.BytesRead, as.ReadErrors, bytes)
+	}
+	if ds.Created.IsZero() || ds.Lifetime <= 0 {
+		t.Errorf("got creation time %v and lifetime %v", ds.Created, ds.Lifetime)
+	}
+
+	// Every active connection is described by a stat.
+	v, err := stats.Value(naming.Join(StatsPrefix, "sim-stats", "conns", strconv.FormatUint(ds.ID, 10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str, _ := v.(string); !strings.Contains(str, ds.LocalAddr+"->"+ds.RemoteAddr) {
+		t.Errorf("got stat %q, want a description of %s", str, ds.LocalAddr)
+	}
+
+	// Errors are counted.
+	l.Disconnect()
+	if _, err := accepted.ReadMsg(); err == nil {
+		t.Fatal("read from a disconnected stream succeeded")
+	}
+	as, _ = findConn(accepted.LocalAddr())
+	if as.ReadErrors != 1 || as.LastError != errDisconnected {
+		t.Errorf("got %d read errors, last error %v, want 1 and %v", as.ReadErrors, as.LastError, errDisconnected)
+	}
+
+	// Closed connections are no longer active.
+	dialed.Close()
+	accepted.Close()
+	if _, ok := findConn(dialed.LocalAddr()); ok {
+		t.Errorf("closed dialed connection is active")
+	}
+	if _, ok := findConn(accepted.LocalAddr()); ok {
+		t.Errorf("closed accepted connection is active")
+	}
+}
+
+func TestConnStatsClose(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	p := newProtocolAdapter("sim-stats-close", NewLoopback(LoopbackOptions{}))
+	dialed, accepted, ln := connect(t, ctx, p, "sim-stats-close")
+	defer ln.Close()
+
+	// The end of the stream isn't a read error.
+	errs := make(chan error, 1)
+	go func() {
+		_, err := dialed.ReadMsg()
+		errs <- err
+	}()
+	dialed.Close()
+	if _, err := accepted.ReadMsg(); err != io.EOF {
+		t.Fatalf("got error %v, want %v", err, io.EOF)
+	}
+	if as := accepted.(*conn).stats.snapshot(); as.ReadErrors != 0 || as.LastError != nil {
+		t.Errorf("got %d read errors, last error %v, want 0 and none", as.ReadErrors, as.LastError)
+	}
+
+	// Nor is the failure of a read pending when the connection is closed.
+	if err := <-errs; err == nil {
+		t.Fatal("read from a closed connection succeeded")
+	}
+	if ds := dialed.(*conn).stats.snapshot(); ds.ReadErrors != 0 || ds.LastError != nil {
+		t.Errorf("got %d read errors, last error %v, want 0 and none", ds.ReadErrors, ds.LastError)
+	}
+	accepted.Close()
+}
@@ -0,0 +1,323 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vom"
+)
+
+const (
+	// ServerCallRecord is the kind of records of calls served by Java invokers.
+	ServerCallRecord = "server"
+	// ClientCallRecord is the kind of records of calls started by Java clients.
+	ClientCallRecord = "client"
+)
+
+// CallRecord is the record of a single RPC that crossed the JNI boundary.
+// Arguments, results and stream items are VOM-encoded.
+type CallRecord struct {
+	Kind            string // ServerCallRecord or ClientCallRecord
+	Name            string // the object name, for client calls, or the suffix, for server calls
+	Method          string
+	Args            [][]byte
+	Results         [][]byte
+	Sent            [][]byte // stream items sent by the recorded side
+	Received        [][]byte // stream items received by the recorded side
+	LocalBlessings  []string
+	RemoteBlessings []string
+	Start           time.Time
+	Duration        time.Duration
+	Error           string // empty if the call succeeded
+}
+
+// Recorder writes call records to a file, one JSON object per line.
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error // first write error, if any
+	// closed is set by Close; records of calls still in progress at that
+	// time are dropped.
+	closed bool
+}
+
+// NewRecorder creates a recorder that writes to the file at the provided
+// path, truncating it.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &Recorder{f: f, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Record writes the provided record.  Write errors are reported by Close.
+// Records are dropped once the recorder is closed.
+func (r *Recorder) Record(rec *CallRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed && r.err == nil {
+		r.err = r.enc.Encode(rec)
+	}
+}
+
+// Close flushes the records and closes the file.  It returns the first error
+// encountered while writing records, if any.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return r.err
+	}
+	r.closed = true
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	if err := r.f.Close(); r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// ReadRecording reads all records from the file at the provided path.
+func ReadRecording(path string) ([]CallRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var recs []CallRecord
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var rec CallRecord
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("record %d of %s: %v", len(recs), path, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+var (
+	activeRecorderMu sync.Mutex
+	activeRecorder   *Recorder // nil if calls aren't being recorded
+)
+
+// StartRecording starts recording all calls that cross the JNI boundary to
+// the file at the provided path.
+func StartRecording(path string) error {
+	activeRecorderMu.Lock()
+	defer activeRecorderMu.Unlock()
+	if activeRecorder != nil {
+		return fmt.Errorf("calls are already being recorded")
+	}
+	r, err := NewRecorder(path)
+	if err != nil {
+		return err
+	}
+	activeRecorder = r
+	return nil
+}
+
+// StopRecording stops recording calls.  Calls in progress at that time are
+// not recorded.
+func StopRecording() error {
+	activeRecorderMu.Lock()
+	r := activeRecorder
+	activeRecorder = nil
+	activeRecorderMu.Unlock()
+	if r == nil {
+		return fmt.Errorf("calls aren't being recorded")
+	}
+	return r.Close()
+}
+
+func currentRecorder() *Recorder {
+	activeRecorderMu.Lock()
+	defer activeRecorderMu.Unlock()
+	return activeRecorder
+}
+
+// callRecording accumulates the record of a call in progress.  All of its
+// methods are no-ops on a nil callRecording, which is what
+// startCallRecording returns when calls aren't being recorded.
+type callRecording struct {
+	r   *Recorder
+	mu  sync.Mutex
+	rec CallRecord
+}
+
+// startCallRecording starts recording a call with the provided arguments,
+// which may be pointers to the actual arguments.
+func startCallRecording(kind, name, method string, args []interface{}) *callRecording {
+	r := currentRecorder()
+	if r == nil {
+		return nil
+	}
+	c := &callRecording{r: r, rec: CallRecord{Kind: kind, Name: name, Method: method, Start: time.Now()}}
+	c.rec.Args = encodeAll(args)
+	return c
+}
+
+// setBlessings records the blessing names of the call's participants.
+func (c *callRecording) setBlessings(local, remote []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.LocalBlessings, c.rec.RemoteBlessings = local, remote
+}
+
+func (c *callRecording) sent(item interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.Sent = append(c.rec.Sent, encode(item))
+}
+
+func (c *callRecording) received(itemptr interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.Received = append(c.rec.Received, encode(itemptr))
+}
+
+// finish writes the record of the call, with the provided results (which may
+// be pointers to the actual results) or error.
+func (c *callRecording) finish(results []interface{}, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.Duration = time.Since(c.rec.Start)
+	if err != nil {
+		c.rec.Error = err.Error()
+	} else {
+		c.rec.Results = encodeAll(results)
+	}
+	c.r.Record(&c.rec)
+}
+
+// encode VOM-encodes the provided value, dereferencing it if it is a
+// pointer.  Values that can't be encoded are recorded as nil.
+func encode(value interface{}) []byte {
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		value = rv.Elem().Interface()
+	}
+	data, err := vom.Encode(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func encodeAll(values []interface{}) [][]byte {
+	ret := make([][]byte, len(values))
+	for i, value := range values {
+		ret[i] = encode(value)
+	}
+	return ret
+}
+
+// recordServerCall starts recording the provided server call, returning the
+// call to be handed to the invoker (which records stream items) along with
+// the recording.  If calls aren't being recorded, the provided call and a nil
+// recording are returned.
+func recordServerCall(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) (rpc.StreamServerCall, *callRecording) {
+	rec := startCallRecording(ServerCallRecord, call.Suffix(), method, argptrs)
+	if rec == nil {
+		return call, nil
+	}
+	if secCall := call.Security(); secCall != nil {
+		remote, _ := security.RemoteBlessingNames(ctx, secCall)
+		rec.setBlessings(security.LocalBlessingNames(ctx, secCall), remote)
+	}
+	return &recordingServerCall{call, rec}, rec
+}
+
+type recordingServerCall struct {
+	rpc.StreamServerCall
+	rec *callRecording
+}
+
+func (c *recordingServerCall) Send(item interface{}) error {
+	err := c.StreamServerCall.Send(item)
+	if err == nil {
+		c.rec.sent(item)
+	}
+	return err
+}
+
+func (c *recordingServerCall) Recv(itemptr interface{}) error {
+	err := c.StreamServerCall.Recv(itemptr)
+	if err == nil {
+		c.rec.received(itemptr)
+	}
+	return err
+}
+
+// recordClientCall returns a client call that records the provided call,
+// started at the provided time with the provided context, once it finishes.  If calls aren't being
+// recorded, the provided call itself is returned.
+func recordClientCall(ctx *context.T, call rpc.ClientCall, start time.Time, name, method string, args []interface{}) rpc.ClientCall {
+	rec := startCallRecording(ClientCallRecord, name, method, args)
+	if rec == nil {
+		return call
+	}
+	rec.rec.Start = start
+	var local []string
+	if secCall := call.Security(); secCall != nil {
+		local = security.LocalBlessingNames(ctx, secCall)
+	}
+	remote, _ := call.RemoteBlessings()
+	rec.setBlessings(local, remote)
+	return &recordingClientCall{call, rec}
+}
+
+type recordingClientCall struct {
+	rpc.ClientCall
+	rec *callRecording
+}
+
+func (c *recordingClientCall) Send(item interface{}) error {
+	err := c.ClientCall.Send(item)
+	if err == nil {
+		c.rec.sent(item)
+	}
+	return err
+}
+
+func (c *recordingClientCall) Recv(itemptr interface{}) error {
+	err := c.ClientCall.Recv(itemptr)
+	if err == nil {
+		c.rec.received(itemptr)
+	}
+	return err
+}
+
+func (c *recordingClientCall) Finish(resultptrs ...interface{}) error {
+	err := c.ClientCall.Finish(resultptrs...)
+	c.rec.finish(resultptrs, err)
+	return err
+}
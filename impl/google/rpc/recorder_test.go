@@ -0,0 +1,171 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+)
+
+// fakeServerCall is an rpc.StreamServerCall that receives the provided items.
+type fakeServerCall struct {
+	rpc.StreamServerCall
+	suffix string
+	items  []int64
+}
+
+func (c *fakeServerCall) Suffix() string              { return c.suffix }
+func (c *fakeServerCall) Security() security.Call     { return security.NewCall(&security.CallParams{}) }
+func (c *fakeServerCall) Send(item interface{}) error { return nil }
+func (c *fakeServerCall) Recv(itemptr interface{}) error {
+	*itemptr.(*int64), c.items = c.items[0], c.items[1:]
+	return nil
+}
+
+// adder is an rpc.Invoker with a single method, Add, which adds its argument
+// to all stream items it receives and sends the sums back.  It returns the
+// number of items received.
+type adder struct {
+	rpc.Invoker
+	offset int64 // added to the results, to simulate a regression
+}
+
+func (a adder) Prepare(ctx *context.T, method string, numArgs int) ([]interface{}, []*vdl.Value, error) {
+	return []interface{}{new(int64), new(int64)}[:numArgs], nil, nil
+}
+
+func (a adder) Invoke(ctx *context.T, call rpc.StreamServerCall, method string, argptrs []interface{}) ([]interface{}, error) {
+	if method != "Add" {
+		return nil, errors.New("unknown method")
+	}
+	n, count := *argptrs[0].(*int64), *argptrs[1].(*int64)
+	for i := int64(0); i < count; i++ {
+		var item int64
+		if err := call.Recv(&item); err != nil {
+			return nil, err
+		}
+		if err := call.Send(item + n); err != nil {
+			return nil, err
+		}
+	}
+	return []interface{}{count + a.offset}, nil
+}
+
+type adderDispatcher struct {
+	adder
+}
+
+func (d adderDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	if suffix != "adder" {
+		return nil, nil, errors.New("no such object")
+	}
+	return d.adder, nil, nil
+}
+
+func recordAdd(t *testing.T, path string, n int64, items ...int64) {
+	if err := StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	count := int64(len(items))
+	argptrs := []interface{}{&n, &count}
+	call, rec := recordServerCall(nil, &fakeServerCall{suffix: "adder", items: items}, "Add", argptrs)
+	results, err := adder{}.Invoke(nil, call, "Add", argptrs)
+	rec.finish(results, err)
+	if err := StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "calls")
+	recordAdd(t, path, 10, 1, 2)
+
+	recs, err := ReadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("got %d records, want 1", len(recs))
+	}
+	rec := recs[0]
+	if rec.Kind != ServerCallRecord || rec.Name != "adder" || rec.Method != "Add" || rec.Error != "" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if len(rec.Args) != 2 || len(rec.Received) != 2 || len(rec.Sent) != 2 || len(rec.Results) != 1 {
+		t.Errorf("got %d args, %d received, %d sent and %d results, want 2, 2, 2 and 1", len(rec.Args), len(rec.Received), len(rec.Sent), len(rec.Results))
+	}
+
+	if diffs := Replay(nil, adderDispatcher{}, recs); len(diffs) != 0 {
+		t.Errorf("replay of the recorded calls differs: %v", diffs)
+	}
+	diffs := Replay(nil, adderDispatcher{adder{offset: 1}}, recs)
+	if len(diffs) != 1 || !strings.Contains(diffs[0], "result 0") {
+		t.Errorf("got differences %v, want a single difference in result 0", diffs)
+	}
+}
+
+func TestRecordingNotStarted(t *testing.T) {
+	call := &fakeServerCall{}
+	if got, rec := recordServerCall(nil, call, "Add", nil); got != call || rec != nil {
+		t.Errorf("calls were recorded without a recorder")
+	}
+	if err := StopRecording(); err == nil {
+		t.Errorf("stopped recording that wasn't started")
+	}
+}
+
+func TestRecordAfterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "calls")
+	r, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Record(&CallRecord{Kind: ServerCallRecord, Name: "before"})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r.Record(&CallRecord{Kind: ServerCallRecord, Name: "after"})
+	if err := r.Close(); err != nil {
+		t.Errorf("second close failed: %v", err)
+	}
+	recs, err := ReadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].Name != "before" {
+		t.Errorf("got records %+v, want only the one written before closing", recs)
+	}
+}
+
+func TestReplayRecordedError(t *testing.T) {
+	recs := []CallRecord{
+		{Kind: ServerCallRecord, Name: "missing", Method: "Add", Error: "no such object", Start: time.Now()},
+		{Kind: ClientCallRecord, Name: naming.Join("a", "b"), Method: "Get"},
+	}
+	if diffs := Replay(nil, adderDispatcher{}, recs); len(diffs) != 0 {
+		t.Errorf("got differences %v, want none", diffs)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+)
+
+// ServiceFactory creates a dispatcher for a Go-implemented service.  It is
+// invoked once for every server (or composite dispatcher) the service is
+// mounted in.
+type ServiceFactory func(ctx *context.T) (rpc.Dispatcher, error)
+
+var (
+	servicesMu sync.Mutex
+	services   = make(map[string]ServiceFactory)
+)
+
+// RegisterService makes the Go service created by the provided factory
+// available to Java under the given name.  It is an error to register two
+// services under the same name.
+func RegisterService(name string, factory ServiceFactory) error {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	if _, ok := services[name]; ok {
+		return fmt.Errorf("Go service %q already registered", name)
+	}
+	services[name] = factory
+	return nil
+}
+
+// RegisteredServices returns the sorted names of all registered Go services.
+func RegisteredServices() []string {
+	servicesMu.Lock()
+	defer servicesMu.Unlock()
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewServiceDispatcher creates a dispatcher for the Go service registered
+// under the provided name.
+func NewServiceDispatcher(ctx *context.T, name string) (rpc.Dispatcher, error) {
+	servicesMu.Lock()
+	factory, ok := services[name]
+	servicesMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no Go service registered under name %q", name)
+	}
+	return factory(ctx)
+}
+
+// ObjectFactory returns a ServiceFactory that serves the provided object,
+// authorized by the provided authorizer, at the empty suffix.  The object is
+// converted into an invoker in the same way as by v23.WithNewServer.
+func ObjectFactory(obj interface{}, auth security.Authorizer) ServiceFactory {
+	return func(*context.T) (rpc.Dispatcher, error) {
+		invoker, err := rpc.ReflectInvoker(obj)
+		if err != nil {
+			return nil, err
+		}
+		return &leafDispatcher{invoker, auth}, nil
+	}
+}
+
+type leafDispatcher struct {
+	invoker rpc.Invoker
+	auth    security.Authorizer
+}
+
+func (d *leafDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	if suffix != "" {
+		return nil, nil, nil
+	}
+	return d.invoker, d.auth, nil
+}
+
+// compositeDispatcher routes lookups for suffixes under the mounted prefixes
+// to the corresponding dispatchers (with the prefix stripped from the
+// suffix), and all other lookups to the fallback dispatcher.
+type compositeDispatcher struct {
+	fallback rpc.Dispatcher
+	prefixes []string // sorted from longest to shortest
+	mounts   map[string]rpc.Dispatcher
+}
+
+// newCompositeDispatcher creates a dispatcher that mounts each of the
+// provided dispatchers under its (non-empty) suffix prefix and uses the
+// fallback dispatcher for all other suffixes.
+func newCompositeDispatcher(fallback rpc.Dispatcher, mounts map[string]rpc.Dispatcher) (*compositeDispatcher, error) {
+	d := &compositeDispatcher{
+		fallback: fallback,
+		mounts:   make(map[string]rpc.Dispatcher),
+	}
+	for prefix, disp := range mounts {
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" {
+			return nil, fmt.Errorf("Go services can't be mounted at the empty suffix of a composite dispatcher")
+		}
+		if _, ok := d.mounts[prefix]; ok {
+			return nil, fmt.Errorf("more than one Go service mounted at suffix %q", prefix)
+		}
+		d.mounts[prefix] = disp
+		d.prefixes = append(d.prefixes, prefix)
+	}
+	sort.Slice(d.prefixes, func(i, j int) bool {
+		return len(d.prefixes[i]) > len(d.prefixes[j])
+	})
+	return d, nil
+}
+
+func (d *compositeDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	for _, prefix := range d.prefixes {
+		if suffix == prefix {
+			return d.mounts[prefix].Lookup(ctx, "")
+		}
+		if strings.HasPrefix(suffix, prefix+"/") {
+			return d.mounts[prefix].Lookup(ctx, suffix[len(prefix)+1:])
+		}
+	}
+	if d.fallback == nil {
+		return nil, nil, nil
+	}
+	return d.fallback.Lookup(ctx, suffix)
+}
+
+// NewCompositeDispatcher creates a dispatcher that serves the Go services
+// registered under the provided names at the given suffix prefixes (i.e.,
+// mounts maps suffix prefixes to service names) and dispatches all other
+// suffixes to the fallback dispatcher, which may be nil.
+func NewCompositeDispatcher(ctx *context.T, fallback rpc.Dispatcher, mounts map[string]string) (rpc.Dispatcher, error) {
+	disps := make(map[string]rpc.Dispatcher)
+	for prefix, name := range mounts {
+		disp, err := NewServiceDispatcher(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		disps[prefix] = disp
+	}
+	return newCompositeDispatcher(fallback, disps)
+}
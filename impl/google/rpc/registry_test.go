@@ -0,0 +1,85 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+)
+
+// recordingDispatcher returns its name and the looked-up suffix as the
+// service object.
+type recordingDispatcher string
+
+func (d recordingDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	return string(d) + ":" + suffix, nil, nil
+}
+
+func TestCompositeDispatcher(t *testing.T) {
+	d, err := newCompositeDispatcher(recordingDispatcher("java"), map[string]rpc.Dispatcher{
+		"echo":      recordingDispatcher("echo"),
+		"echo/fast": recordingDispatcher("fast"),
+		"/stats/":   recordingDispatcher("stats"),
+	})
+	if err != nil {
+		t.Fatalf("couldn't create composite dispatcher: %v", err)
+	}
+	tests := []struct {
+		suffix, want string
+	}{
+		{"", "java:"},
+		{"echo", "echo:"},
+		{"echo/a/b", "echo:a/b"},
+		{"echo/fast", "fast:"},
+		{"echo/fast/a", "fast:a"},
+		{"echoes", "java:echoes"},
+		{"stats/x", "stats:x"},
+		{"other/echo", "java:other/echo"},
+	}
+	for _, test := range tests {
+		obj, _, err := d.Lookup(nil, test.suffix)
+		if err != nil {
+			t.Errorf("Lookup(%q) failed: %v", test.suffix, err)
+			continue
+		}
+		if got := obj.(string); got != test.want {
+			t.Errorf("Lookup(%q): got %q, want %q", test.suffix, got, test.want)
+		}
+	}
+	if _, err := newCompositeDispatcher(nil, map[string]rpc.Dispatcher{"/": recordingDispatcher("root")}); err == nil {
+		t.Errorf("expected error for a service mounted at the empty suffix")
+	}
+}
+
+func TestRegisterService(t *testing.T) {
+	factory := func(*context.T) (rpc.Dispatcher, error) { return recordingDispatcher("test"), nil }
+	if err := RegisterService("test/registry", factory); err != nil {
+		t.Fatalf("couldn't register service: %v", err)
+	}
+	defer func() {
+		servicesMu.Lock()
+		delete(services, "test/registry")
+		servicesMu.Unlock()
+	}()
+	if err := RegisterService("test/registry", factory); err == nil {
+		t.Errorf("expected error when registering the same name twice")
+	}
+	if _, err := NewServiceDispatcher(nil, "test/unknown"); err == nil {
+		t.Errorf("expected error for an unregistered service")
+	}
+	d, err := NewCompositeDispatcher(nil, nil, map[string]string{"svc": "test/registry"})
+	if err != nil {
+		t.Fatalf("couldn't create composite dispatcher: %v", err)
+	}
+	if obj, _, _ := d.Lookup(nil, "svc/x"); obj != "test:x" {
+		t.Errorf("got %v, want %q", obj, "test:x")
+	}
+	if obj, _, _ := d.Lookup(nil, "other"); obj != nil {
+		t.Errorf("got %v for an unmounted suffix, want nil", obj)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/v23/vom"
+)
+
+// Replay invokes the calls described by the provided server call records on
+// the objects that the provided dispatcher returns for the recorded suffixes,
+// and returns a description of every difference between the recorded
+// outcomes (errors, results and sent stream items) and the replayed ones.
+// Recorded client calls are skipped.
+//
+// Replayed calls are not authorized, and they receive the recorded stream
+// items in order; their security call carries only the method, suffix and
+// timestamp of the recorded call.
+func Replay(ctx *context.T, d rpc.Dispatcher, recs []CallRecord) []string {
+	var diffs []string
+	for i, rec := range recs {
+		if rec.Kind != ServerCallRecord {
+			continue
+		}
+		for _, diff := range replayCall(ctx, d, &rec) {
+			diffs = append(diffs, fmt.Sprintf("call %d (%s on %q): %s", i, rec.Method, rec.Name, diff))
+		}
+	}
+	return diffs
+}
+
+func replayCall(ctx *context.T, d rpc.Dispatcher, rec *CallRecord) []string {
+	call := &replayServerCall{rec: rec}
+	results, err := invokeRecorded(ctx, d, call, rec)
+	if err != nil {
+		if rec.Error == "" {
+			return []string{fmt.Sprintf("got error %q, recorded success", err)}
+		}
+		return nil
+	}
+	if rec.Error != "" {
+		return []string{fmt.Sprintf("succeeded, recorded error %q", rec.Error)}
+	}
+	diffs := diffEncoded("result", rec.Results, encodeAll(results))
+	return append(diffs, diffEncoded("sent stream item", rec.Sent, call.sent)...)
+}
+
+// invokeRecorded invokes the recorded call on the object that d returns for
+// the recorded suffix.
+func invokeRecorded(ctx *context.T, d rpc.Dispatcher, call *replayServerCall, rec *CallRecord) ([]interface{}, error) {
+	obj, _, err := d.Lookup(ctx, rec.Name)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("no object for suffix %q", rec.Name)
+	}
+	inv, ok := obj.(rpc.Invoker)
+	if !ok {
+		if inv, err = rpc.ReflectInvoker(obj); err != nil {
+			return nil, err
+		}
+	}
+	argptrs, _, err := inv.Prepare(ctx, rec.Method, len(rec.Args))
+	if err != nil {
+		return nil, err
+	}
+	for i, arg := range rec.Args {
+		if err := vom.Decode(arg, argptrs[i]); err != nil {
+			return nil, fmt.Errorf("couldn't decode argument %d: %v", i, err)
+		}
+	}
+	return inv.Invoke(ctx, call, rec.Method, argptrs)
+}
+
+// diffEncoded describes the differences between the recorded and replayed
+// VOM-encoded values.  Values are compared after decoding, so that
+// differences in encoding alone are ignored.
+func diffEncoded(what string, recorded, replayed [][]byte) []string {
+	if len(recorded) != len(replayed) {
+		return []string{fmt.Sprintf("got %d %ss, recorded %d", len(replayed), what, len(recorded))}
+	}
+	var diffs []string
+	for i := range recorded {
+		var want, got *vdl.Value
+		errWant := vom.Decode(recorded[i], &want)
+		errGot := vom.Decode(replayed[i], &got)
+		if errWant != nil || errGot != nil || !vdl.EqualValue(want, got) {
+			diffs = append(diffs, fmt.Sprintf("%s %d is %v, recorded %v", what, i, got, want))
+		}
+	}
+	return diffs
+}
+
+// replayServerCall is the server call handed to replayed invocations.
+type replayServerCall struct {
+	rpc.StreamServerCall // not implemented; only the methods below may be invoked
+	rec                  *CallRecord
+
+	mu       sync.Mutex
+	received int
+	sent     [][]byte
+}
+
+func (c *replayServerCall) Send(item interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, encode(item))
+	return nil
+}
+
+func (c *replayServerCall) Recv(itemptr interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.received >= len(c.rec.Received) {
+		return io.EOF
+	}
+	c.received++
+	return vom.Decode(c.rec.Received[c.received-1], itemptr)
+}
+
+func (c *replayServerCall) Security() security.Call {
+	return security.NewCall(&security.CallParams{
+		Timestamp: c.rec.Start,
+		Method:    c.rec.Method,
+		Suffix:    c.rec.Name,
+	})
+}
+
+func (c *replayServerCall) Suffix() string {
+	return c.rec.Name
+}
+
+func (c *replayServerCall) LocalEndpoint() naming.Endpoint {
+	return naming.Endpoint{}
+}
+
+func (c *replayServerCall) RemoteEndpoint() naming.Endpoint {
+	return naming.Endpoint{}
+}
+
+func (c *replayServerCall) GrantedBlessings() security.Blessings {
+	return security.Blessings{}
+}
+
+func (c *replayServerCall) Server() rpc.Server {
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+)
+
+// serverStatusChange describes how a server's status changed between two
+// snapshots.
+type serverStatusChange struct {
+	// Status is the new status of the server.
+	Status rpc.ServerStatus
+	// StateChanged is true iff the server's state differs from the
+	// previous snapshot.
+	StateChanged bool
+	// AddedEndpoints and RemovedEndpoints are the endpoints that the server
+	// started and stopped listening on, respectively.
+	AddedEndpoints, RemovedEndpoints []naming.Endpoint
+	// ChangedPublisherEntries are the publisher entries that are new or
+	// have changed; RemovedPublisherEntries are the entries that are gone.
+	ChangedPublisherEntries, RemovedPublisherEntries []rpc.PublisherEntry
+	// NewListenErrors are the listen errors that are new or have changed;
+	// ClearedListenErrors are the addresses whose errors are gone.
+	NewListenErrors     map[struct{ Protocol, Address string }]error
+	ClearedListenErrors rpc.ListenAddrs
+	// NewProxyErrors are the proxy errors that are new or have changed;
+	// ClearedProxyErrors are the proxies whose errors are gone.
+	NewProxyErrors     map[string]error
+	ClearedProxyErrors []string
+}
+
+// statusChangeValue is a server status change as seen by the channel
+// combinators, which filter the changes on their fields.  Endpoints are
+// listed by their string form and the state by the name of the Java
+// ServerState constant.
+type statusChangeValue struct {
+	State            string
+	StateChanged     bool
+	ServesMountTable bool
+	Endpoints        []string
+	AddedEndpoints   []string
+	RemovedEndpoints []string
+
+	change *serverStatusChange // the change converted to Java
+}
+
+// value returns the value of the change seen by the channel combinators.
+func (c *serverStatusChange) value() (statusChangeValue, error) {
+	state, err := javaServerStateName(c.Status.State)
+	if err != nil {
+		return statusChangeValue{}, err
+	}
+	return statusChangeValue{
+		State:            state,
+		StateChanged:     c.StateChanged,
+		ServesMountTable: c.Status.ServesMountTable,
+		Endpoints:        endpointStrings(c.Status.Endpoints),
+		AddedEndpoints:   endpointStrings(c.AddedEndpoints),
+		RemovedEndpoints: endpointStrings(c.RemovedEndpoints),
+		change:           c,
+	}, nil
+}
+
+// javaServerStateName returns the name of the Java ServerState constant that
+// corresponds to the provided state.
+func javaServerStateName(state rpc.ServerState) (string, error) {
+	switch state {
+	case rpc.ServerActive:
+		return "SERVER_ACTIVE", nil
+	case rpc.ServerStopping:
+		return "SERVER_STOPPING", nil
+	case rpc.ServerStopped:
+		return "SERVER_STOPPED", nil
+	}
+	return "", fmt.Errorf("Unrecognized state: %d", state)
+}
+
+func endpointStrings(eps []naming.Endpoint) []string {
+	strs := make([]string, len(eps))
+	for i, ep := range eps {
+		strs[i] = ep.String()
+	}
+	return strs
+}
+
+// empty returns true iff the change doesn't describe any difference.
+func (c *serverStatusChange) empty() bool {
+	return !c.StateChanged &&
+		len(c.AddedEndpoints) == 0 && len(c.RemovedEndpoints) == 0 &&
+		len(c.ChangedPublisherEntries) == 0 && len(c.RemovedPublisherEntries) == 0 &&
+		len(c.NewListenErrors) == 0 && len(c.ClearedListenErrors) == 0 &&
+		len(c.NewProxyErrors) == 0 && len(c.ClearedProxyErrors) == 0
+}
+
+// diffServerStatus computes the change from the old to the new server status.
+// Results are sorted so that they don't depend on map iteration order.
+func diffServerStatus(old, new rpc.ServerStatus) *serverStatusChange {
+	c := &serverStatusChange{
+		Status:          new,
+		StateChanged:    old.State != new.State,
+		NewListenErrors: make(map[struct{ Protocol, Address string }]error),
+		NewProxyErrors:  make(map[string]error),
+	}
+
+	oldEps := make(map[string]naming.Endpoint)
+	for _, ep := range old.Endpoints {
+		oldEps[ep.String()] = ep
+	}
+	newEps := make(map[string]bool)
+	for _, ep := range new.Endpoints {
+		newEps[ep.String()] = true
+		if _, ok := oldEps[ep.String()]; !ok {
+			c.AddedEndpoints = append(c.AddedEndpoints, ep)
+		}
+	}
+	for _, ep := range old.Endpoints {
+		if !newEps[ep.String()] {
+			c.RemovedEndpoints = append(c.RemovedEndpoints, ep)
+		}
+	}
+
+	pubKey := func(e rpc.PublisherEntry) string { return e.Name + "\x00" + e.Server }
+	oldPubs := make(map[string]rpc.PublisherEntry)
+	for _, e := range old.PublisherStatus {
+		oldPubs[pubKey(e)] = e
+	}
+	newPubs := make(map[string]bool)
+	for _, e := range new.PublisherStatus {
+		newPubs[pubKey(e)] = true
+		if oldEntry, ok := oldPubs[pubKey(e)]; !ok || !reflect.DeepEqual(oldEntry, e) {
+			c.ChangedPublisherEntries = append(c.ChangedPublisherEntries, e)
+		}
+	}
+	for _, e := range old.PublisherStatus {
+		if !newPubs[pubKey(e)] {
+			c.RemovedPublisherEntries = append(c.RemovedPublisherEntries, e)
+		}
+	}
+
+	for addr, err := range new.ListenErrors {
+		if !sameError(old.ListenErrors[addr], err) {
+			c.NewListenErrors[addr] = err
+		}
+	}
+	for addr := range old.ListenErrors {
+		if _, ok := new.ListenErrors[addr]; !ok {
+			c.ClearedListenErrors = append(c.ClearedListenErrors, addr)
+		}
+	}
+	sort.Slice(c.ClearedListenErrors, func(i, j int) bool {
+		a, b := c.ClearedListenErrors[i], c.ClearedListenErrors[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Address < b.Address
+	})
+
+	for proxy, err := range new.ProxyErrors {
+		if !sameError(old.ProxyErrors[proxy], err) {
+			c.NewProxyErrors[proxy] = err
+		}
+	}
+	for proxy := range old.ProxyErrors {
+		if _, ok := new.ProxyErrors[proxy]; !ok {
+			c.ClearedProxyErrors = append(c.ClearedProxyErrors, proxy)
+		}
+	}
+	sort.Strings(c.ClearedProxyErrors)
+	return c
+}
+
+func sameError(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Error() == b.Error()
+}
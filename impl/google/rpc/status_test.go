@@ -0,0 +1,115 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"v.io/v23/naming"
+	"v.io/v23/rpc"
+	"v.io/v23/vdl"
+)
+
+func mustParseEndpoint(t *testing.T, s string) naming.Endpoint {
+	ep, err := naming.ParseEndpoint(s)
+	if err != nil {
+		t.Fatalf("couldn't parse endpoint %q: %v", s, err)
+	}
+	return ep
+}
+
+func TestDiffServerStatus(t *testing.T) {
+	ep1 := mustParseEndpoint(t, "@6@tcp@127.0.0.1:1001@@@@@@")
+	ep2 := mustParseEndpoint(t, "@6@tcp@127.0.0.1:1002@@@@@@")
+	ep3 := mustParseEndpoint(t, "@6@tcp@127.0.0.1:1003@@@@@@")
+	tcp := struct{ Protocol, Address string }{"tcp", ":0"}
+	bt := struct{ Protocol, Address string }{"bt", "/0"}
+	old := rpc.ServerStatus{
+		State:     rpc.ServerActive,
+		Endpoints: []naming.Endpoint{ep1, ep2},
+		PublisherStatus: []rpc.PublisherEntry{
+			{Name: "a", Server: "s1"},
+			{Name: "b", Server: "s1"},
+		},
+		ListenErrors: map[struct{ Protocol, Address string }]error{tcp: errors.New("tcp failed")},
+		ProxyErrors:  map[string]error{"proxy": errors.New("proxy down")},
+	}
+	new := rpc.ServerStatus{
+		State:     rpc.ServerActive,
+		Endpoints: []naming.Endpoint{ep2, ep3},
+		PublisherStatus: []rpc.PublisherEntry{
+			{Name: "a", Server: "s1", LastMountErr: errors.New("mount failed")},
+			{Name: "c", Server: "s1"},
+		},
+		ListenErrors: map[struct{ Protocol, Address string }]error{bt: errors.New("bt failed")},
+		ProxyErrors:  map[string]error{"proxy": errors.New("proxy down")},
+	}
+	c := diffServerStatus(old, new)
+	if c.StateChanged {
+		t.Errorf("state reported as changed")
+	}
+	if !reflect.DeepEqual(c.AddedEndpoints, []naming.Endpoint{ep3}) || !reflect.DeepEqual(c.RemovedEndpoints, []naming.Endpoint{ep1}) {
+		t.Errorf("got added endpoints %v and removed endpoints %v, want [%v] and [%v]", c.AddedEndpoints, c.RemovedEndpoints, ep3, ep1)
+	}
+	if len(c.ChangedPublisherEntries) != 2 || c.ChangedPublisherEntries[0].Name != "a" || c.ChangedPublisherEntries[1].Name != "c" {
+		t.Errorf("got changed publisher entries %v, want entries for a and c", c.ChangedPublisherEntries)
+	}
+	if len(c.RemovedPublisherEntries) != 1 || c.RemovedPublisherEntries[0].Name != "b" {
+		t.Errorf("got removed publisher entries %v, want the entry for b", c.RemovedPublisherEntries)
+	}
+	if _, ok := c.NewListenErrors[bt]; !ok || len(c.NewListenErrors) != 1 {
+		t.Errorf("got new listen errors %v, want only the bt error", c.NewListenErrors)
+	}
+	if !reflect.DeepEqual(c.ClearedListenErrors, rpc.ListenAddrs{tcp}) {
+		t.Errorf("got cleared listen errors %v, want [%v]", c.ClearedListenErrors, tcp)
+	}
+	if len(c.NewProxyErrors) != 0 || len(c.ClearedProxyErrors) != 0 {
+		t.Errorf("got proxy error changes %v and %v, want none", c.NewProxyErrors, c.ClearedProxyErrors)
+	}
+	if c.empty() {
+		t.Errorf("change reported as empty")
+	}
+	if c := diffServerStatus(new, new); !c.empty() {
+		t.Errorf("got non-empty change %+v between identical statuses", c)
+	}
+	new.State = rpc.ServerStopping
+	if c := diffServerStatus(old, new); !c.StateChanged {
+		t.Errorf("state change not reported")
+	}
+}
+
+func TestStatusChangeValue(t *testing.T) {
+	ep1 := mustParseEndpoint(t, "@6@tcp@127.0.0.1:1001@@@@@@")
+	ep2 := mustParseEndpoint(t, "@6@tcp@127.0.0.1:1002@@@@@@")
+	old := rpc.ServerStatus{State: rpc.ServerActive, Endpoints: []naming.Endpoint{ep1}}
+	new := rpc.ServerStatus{State: rpc.ServerStopping, ServesMountTable: true, Endpoints: []naming.Endpoint{ep2}}
+	c := diffServerStatus(old, new)
+	value, err := c.value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := statusChangeValue{
+		State:            "SERVER_STOPPING",
+		StateChanged:     true,
+		ServesMountTable: true,
+		Endpoints:        []string{ep2.String()},
+		AddedEndpoints:   []string{ep2.String()},
+		RemovedEndpoints: []string{ep1.String()},
+		change:           c,
+	}
+	if !reflect.DeepEqual(value, want) {
+		t.Errorf("got value %+v, want %+v", value, want)
+	}
+	// The channel combinators filter changes on the fields of their VDL value.
+	v, err := vdl.ValueFromReflect(reflect.ValueOf(value))
+	if err != nil {
+		t.Fatalf("change value isn't a VDL value: %v", err)
+	}
+	if state := v.StructFieldByName("State"); state == nil || state.RawString() != "SERVER_STOPPING" {
+		t.Errorf("got VDL state %v, want SERVER_STOPPING", state)
+	}
+}
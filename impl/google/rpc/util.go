@@ -71,8 +71,8 @@ func javaStreamServerCall(env jutil.Env, jContext jutil.Object, call rpc.StreamS
 	return jStreamServerCall, nil
 }
 
-// javaCall converts the provided Go Call value into a Java Call object.
-func javaCall(env jutil.Env, jContext jutil.Object, call rpc.ClientCall) (jutil.Object, error) {
+// javaCall converts the provided Go client call into a Java Call object.
+func javaCall(env jutil.Env, jContext jutil.Object, call *clientCall) (jutil.Object, error) {
 	if call == nil {
 		return jutil.NullObject, fmt.Errorf("Go Call value cannot be nil")
 	}
@@ -80,7 +80,7 @@ func javaCall(env jutil.Env, jContext jutil.Object, call rpc.ClientCall) (jutil.
 	if err != nil {
 		return jutil.NullObject, err
 	}
-	ref := jutil.GoNewRef(&call) // Un-refed when the Java Call object is finalized.
+	ref := jutil.GoNewRef(call) // Un-refed when the Java Call object is finalized.
 	jCall, err := jutil.NewObject(env, jClientCallImplClass, []jutil.Sign{contextSign, jutil.LongSign, streamSign}, jContext, int64(ref), jStream)
 	if err != nil {
 		jutil.GoDecRef(ref)
@@ -170,16 +170,9 @@ func JavaServerStatus(env jutil.Env, status rpc.ServerStatus) (jutil.Object, err
 // JavaServerState converts the provided rpc.ServerState value into a Java
 // ServerState enum.
 func JavaServerState(env jutil.Env, state rpc.ServerState) (jutil.Object, error) {
-	var name string
-	switch state {
-	case rpc.ServerActive:
-		name = "SERVER_ACTIVE"
-	case rpc.ServerStopping:
-		name = "SERVER_STOPPING"
-	case rpc.ServerStopped:
-		name = "SERVER_STOPPED"
-	default:
-		return jutil.NullObject, fmt.Errorf("Unrecognized state: %d", state)
+	name, err := javaServerStateName(state)
+	if err != nil {
+		return jutil.NullObject, err
 	}
 	return jutil.CallStaticObjectMethod(env, jServerStateClass, "valueOf", []jutil.Sign{jutil.StringSign}, serverStateSign, name)
 }
@@ -397,3 +390,67 @@ func GoAddressChooser(env jutil.Env, jChooser jutil.Object) rpc.AddressChooser {
 	})
 	return chooser
 }
+
+// javaServerStatusChange converts the provided server status change into a
+// Java ServerStatusChange object.
+func javaServerStatusChange(env jutil.Env, change *serverStatusChange) (jutil.Object, error) {
+	jStatus, err := JavaServerStatus(env, change.Status)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	publisherEntries := func(entries []rpc.PublisherEntry) (jutil.Object, error) {
+		arr := make([]jutil.Object, len(entries))
+		for i, e := range entries {
+			var err error
+			if arr[i], err = JavaPublisherEntry(env, e); err != nil {
+				return jutil.NullObject, err
+			}
+		}
+		return jutil.JObjectArray(env, arr, jPublisherEntryClass)
+	}
+	jChangedPubs, err := publisherEntries(change.ChangedPublisherEntries)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	jRemovedPubs, err := publisherEntries(change.RemovedPublisherEntries)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	lnErrors := make(map[jutil.Object]jutil.Object)
+	for addr, lerr := range change.NewListenErrors {
+		jAddr, err := JavaListenAddr(env, addr.Protocol, addr.Address)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		jVExp, err := jutil.JVException(env, lerr)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		lnErrors[jAddr] = jVExp
+	}
+	jLnErrors, err := jutil.JObjectMap(env, lnErrors)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	jClearedLnErrors, err := JavaListenAddrArray(env, change.ClearedListenErrors)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	proxyErrors := make(map[jutil.Object]jutil.Object)
+	for s, perr := range change.NewProxyErrors {
+		jVExp, err := jutil.JVException(env, perr)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		proxyErrors[jutil.JString(env, s)] = jVExp
+	}
+	jProxyErrors, err := jutil.JObjectMap(env, proxyErrors)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	serverStatusSign := jutil.ClassSign("io.v.v23.rpc.ServerStatus")
+	publisherEntrySign := jutil.ClassSign("io.v.v23.rpc.PublisherEntry")
+	return jutil.NewObject(env, jServerStatusChangeClass,
+		[]jutil.Sign{serverStatusSign, jutil.BoolSign, jutil.ArraySign(jutil.StringSign), jutil.ArraySign(jutil.StringSign), jutil.ArraySign(publisherEntrySign), jutil.ArraySign(publisherEntrySign), jutil.MapSign, jutil.ArraySign(listenAddrSign), jutil.MapSign, jutil.ArraySign(jutil.StringSign)},
+		jStatus, change.StateChanged, endpointStrings(change.AddedEndpoints), endpointStrings(change.RemovedEndpoints), jChangedPubs, jRemovedPubs, jLnErrors, jClearedLnErrors, jProxyErrors, change.ClearedProxyErrors)
+}
@@ -0,0 +1,216 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"v.io/v23/context"
+	"v.io/v23/naming"
+	"v.io/v23/security"
+	"v.io/v23/vdl"
+	"v.io/x/lib/vlog"
+	"v.io/x/ref/lib/vdl/build"
+	"v.io/x/ref/lib/vdl/compile"
+)
+
+// runtimeConfig is the declarative configuration of a runtime, as read from a
+// JSON or VDL config file.  Sections that are left unset don't modify the
+// corresponding runtime setting.
+type runtimeConfig struct {
+	Logging    *loggingConfig           `json:"logging,omitempty"`
+	ListenSpec *listenSpecConfig        `json:"listenSpec,omitempty"`
+	Namespace  *namespaceConfig         `json:"namespace,omitempty"`
+	Principal  *principalConfig         `json:"principal,omitempty"`
+	Services   map[string]ServiceConfig `json:"services,omitempty"`
+}
+
+type loggingConfig struct {
+	Dir      string `json:"dir,omitempty"`
+	ToStderr bool   `json:"toStderr,omitempty"`
+	Level    int    `json:"level,omitempty"`
+	VModule  string `json:"vmodule,omitempty"`
+	// vmodule is VModule parsed, as filled in by validate.
+	vmodule vlog.ModuleSpec
+}
+
+type listenSpecConfig struct {
+	Addrs []listenAddrConfig `json:"addrs,omitempty"`
+	Proxy string             `json:"proxy,omitempty"`
+}
+
+type listenAddrConfig struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+}
+
+type namespaceConfig struct {
+	Roots []string `json:"roots"`
+}
+
+type principalConfig struct {
+	Dir string `json:"dir"`
+}
+
+// ServiceConfig holds the parameters of one of the services that can be
+// started from Java (i.e., mounttable, syncbase or groups).
+type ServiceConfig struct {
+	Name           string `json:"name,omitempty"`
+	StorageRootDir string `json:"storageRootDir,omitempty"`
+	StatsPrefix    string `json:"statsPrefix,omitempty"`
+}
+
+var knownServices = map[string]bool{
+	"mounttable": true,
+	"syncbase":   true,
+	"groups":     true,
+}
+
+type configKey struct{}
+
+// ServiceParams returns the parameters with which the provided service is
+// started: the provided parameters, with the empty ones replaced by those
+// configured for the service in the config applied to ctx, if any.
+func ServiceParams(ctx *context.T, service string, params ServiceConfig) ServiceConfig {
+	applied, ok := ctx.Value(configKey{}).(*appliedConfig)
+	if !ok {
+		return params
+	}
+	defaults := applied.cfg.Services[service]
+	if params.Name == "" {
+		params.Name = defaults.Name
+	}
+	if params.StorageRootDir == "" {
+		params.StorageRootDir = defaults.StorageRootDir
+	}
+	if params.StatsPrefix == "" {
+		params.StatsPrefix = defaults.StatsPrefix
+	}
+	return params
+}
+
+// appliedConfig is the config applied to a context, i.e., the merge of all of
+// the configs applied to it and its ancestors.
+type appliedConfig struct {
+	cfg *runtimeConfig
+	// principal is the principal loaded from cfg.Principal, if any.
+	principal security.Principal
+}
+
+// merge returns the config that results from applying the provided config on
+// top of c: the sections set in the provided config replace those of c, and
+// so do the parameters of the services it configures.
+func (c *runtimeConfig) merge(o *runtimeConfig) *runtimeConfig {
+	merged := *c
+	if o.Logging != nil {
+		merged.Logging = o.Logging
+	}
+	if o.ListenSpec != nil {
+		merged.ListenSpec = o.ListenSpec
+	}
+	if o.Namespace != nil {
+		merged.Namespace = o.Namespace
+	}
+	if o.Principal != nil {
+		merged.Principal = o.Principal
+	}
+	if len(o.Services) > 0 {
+		merged.Services = make(map[string]ServiceConfig, len(c.Services)+len(o.Services))
+		for name, svc := range c.Services {
+			merged.Services[name] = svc
+		}
+		for name, svc := range o.Services {
+			merged.Services[name] = svc
+		}
+	}
+	return &merged
+}
+
+// configError describes an invalid value of a single config field.
+type configError struct {
+	field string
+	msg   string
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("invalid config field %q: %s", e.field, e.msg)
+}
+
+// parseConfig parses the provided config file contents.  Files with a ".vdl"
+// extension are parsed as VDL config files; all other files are parsed as
+// JSON.  The returned config has been validated.
+func parseConfig(filename string, data []byte) (*runtimeConfig, error) {
+	cfg := new(runtimeConfig)
+	if filepath.Ext(filename) == ".vdl" {
+		env := compile.NewEnv(-1)
+		build.BuildConfigValue(filename, bytes.NewReader(data), vdl.TypeOf(*cfg), env, cfg)
+		if err := env.Errors.ToError(); err != nil {
+			return nil, fmt.Errorf("couldn't parse VDL config %q: %v", filename, err)
+		}
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("couldn't parse JSON config %q: %v", filename, err)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate checks the config for invalid values, returning a *configError
+// naming the first offending field.  It also fills in the parsed form of the
+// fields that have one.
+func (c *runtimeConfig) validate() error {
+	if l := c.Logging; l != nil {
+		if l.Level < 0 {
+			return &configError{"logging.level", "must not be negative"}
+		}
+		if err := l.vmodule.Set(l.VModule); err != nil {
+			return &configError{"logging.vmodule", err.Error()}
+		}
+	}
+	if s := c.ListenSpec; s != nil {
+		for i, addr := range s.Addrs {
+			if addr.Protocol == "" {
+				return &configError{fmt.Sprintf("listenSpec.addrs[%d].protocol", i), "must not be empty"}
+			}
+			if addr.Address == "" {
+				return &configError{fmt.Sprintf("listenSpec.addrs[%d].address", i), "must not be empty"}
+			}
+		}
+	}
+	if n := c.Namespace; n != nil {
+		for i, root := range n.Roots {
+			if !naming.Rooted(root) {
+				return &configError{fmt.Sprintf("namespace.roots[%d]", i), fmt.Sprintf("%q must be a rooted name", root)}
+			}
+		}
+	}
+	if p := c.Principal; p != nil && p.Dir == "" {
+		return &configError{"principal.dir", "must not be empty"}
+	}
+	names := make([]string, 0, len(c.Services))
+	for name := range c.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		svc := c.Services[name]
+		if !knownServices[name] {
+			return &configError{fmt.Sprintf("services.%s", name), "unknown service; must be one of mounttable, syncbase or groups"}
+		}
+		if name != "mounttable" && svc.StatsPrefix != "" {
+			return &configError{fmt.Sprintf("services.%s.statsPrefix", name), "only supported by the mounttable service"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rt
+
+import (
+	"reflect"
+	"testing"
+
+	"v.io/v23/context"
+)
+
+func TestParseConfig(t *testing.T) {
+	data := []byte(`{
+		"logging": {"level": 2, "vmodule": "rpc=3"},
+		"listenSpec": {"addrs": [{"protocol": "tcp", "address": ":0"}], "proxy": "proxy"},
+		"namespace": {"roots": ["/ns.dev.v.io:8101"]},
+		"principal": {"dir": "/data/credentials"},
+		"services": {"mounttable": {"name": "mt", "statsPrefix": "mt"}}
+	}`)
+	cfg, err := parseConfig("runtime.json", data)
+	if err != nil {
+		t.Fatalf("couldn't parse config: %v", err)
+	}
+	want := &runtimeConfig{
+		Logging: &loggingConfig{Level: 2, VModule: "rpc=3"},
+		ListenSpec: &listenSpecConfig{
+			Addrs: []listenAddrConfig{{"tcp", ":0"}},
+			Proxy: "proxy",
+		},
+		Namespace: &namespaceConfig{Roots: []string{"/ns.dev.v.io:8101"}},
+		Principal: &principalConfig{Dir: "/data/credentials"},
+		Services:  map[string]ServiceConfig{"mounttable": {Name: "mt", StatsPrefix: "mt"}},
+	}
+	// validate fills in the parsed vmodule spec.
+	if err := want.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got config %#v, want %#v", cfg, want)
+	}
+}
+
+func TestParseVDLConfig(t *testing.T) {
+	data := []byte(`config = {
+		Logging: {Level: 2, VModule: "rpc=3"},
+		ListenSpec: {Addrs: {{Protocol: "tcp", Address: ":0"}}, Proxy: "proxy"},
+		Namespace: {Roots: {"/ns.dev.v.io:8101"}},
+		Principal: {Dir: "/data/credentials"},
+		Services: {"syncbase": {StorageRootDir: "/data/syncbase"}},
+	}`)
+	cfg, err := parseConfig("runtime.vdl", data)
+	if err != nil {
+		t.Fatalf("couldn't parse config: %v", err)
+	}
+	want := &runtimeConfig{
+		Logging: &loggingConfig{Level: 2, VModule: "rpc=3"},
+		ListenSpec: &listenSpecConfig{
+			Addrs: []listenAddrConfig{{"tcp", ":0"}},
+			Proxy: "proxy",
+		},
+		Namespace: &namespaceConfig{Roots: []string{"/ns.dev.v.io:8101"}},
+		Principal: &principalConfig{Dir: "/data/credentials"},
+		Services:  map[string]ServiceConfig{"syncbase": {StorageRootDir: "/data/syncbase"}},
+	}
+	// validate fills in the parsed vmodule spec.
+	if err := want.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got config %#v, want %#v", cfg, want)
+	}
+	// VDL configs are validated too.
+	_, err = parseConfig("runtime.vdl", []byte(`config = {Principal: {}}`))
+	if cerr, ok := err.(*configError); !ok || cerr.field != "principal.dir" {
+		t.Errorf("got error %v, want an invalid principal.dir", err)
+	}
+	if _, err := parseConfig("runtime.vdl", []byte(`config = {Unknown: {}}`)); err == nil {
+		t.Errorf("expected error for unknown config field")
+	}
+}
+
+func TestServiceParams(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	params := ServiceConfig{Name: "mt", StorageRootDir: "/data/mt"}
+	if got := ServiceParams(ctx, "mounttable", params); got != params {
+		t.Errorf("got params %v without a config, want %v", got, params)
+	}
+
+	first := &runtimeConfig{
+		Logging:  &loggingConfig{Level: 1},
+		Services: map[string]ServiceConfig{"mounttable": {Name: "cfg-mt", StatsPrefix: "cfg-stats"}},
+	}
+	second := &runtimeConfig{
+		Logging:  &loggingConfig{Level: 2},
+		Services: map[string]ServiceConfig{"syncbase": {StorageRootDir: "/cfg/syncbase"}},
+	}
+	merged := first.merge(second)
+	ctx = context.WithValue(ctx, configKey{}, &appliedConfig{cfg: merged})
+	if merged.Logging.Level != 2 {
+		t.Errorf("got logging level %d, want the last one applied, 2", merged.Logging.Level)
+	}
+
+	// The params that are set override the config.
+	want := ServiceConfig{Name: "mt", StorageRootDir: "/data/mt", StatsPrefix: "cfg-stats"}
+	if got := ServiceParams(ctx, "mounttable", params); got != want {
+		t.Errorf("got params %v, want %v", got, want)
+	}
+	// Services configured by earlier configs keep their params.
+	want = ServiceConfig{Name: "sb", StorageRootDir: "/cfg/syncbase"}
+	if got := ServiceParams(ctx, "syncbase", ServiceConfig{Name: "sb"}); got != want {
+		t.Errorf("got params %v, want %v", got, want)
+	}
+	if got := ServiceParams(ctx, "groups", ServiceConfig{}); got != (ServiceConfig{}) {
+		t.Errorf("got params %v for an unconfigured service, want none", got)
+	}
+	// Merging doesn't modify the merged configs.
+	if len(first.Services) != 1 || first.Logging.Level != 1 {
+		t.Errorf("merging modified the original config: %#v", first)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		data  string
+		field string
+	}{
+		{`{"logging": {"level": -1}}`, "logging.level"},
+		{`{"listenSpec": {"addrs": [{"protocol": "tcp", "address": ":0"}, {"address": ":0"}]}}`, "listenSpec.addrs[1].protocol"},
+		{`{"listenSpec": {"addrs": [{"protocol": "tcp"}]}}`, "listenSpec.addrs[0].address"},
+		{`{"namespace": {"roots": ["/ns.dev.v.io:8101", "relative"]}}`, "namespace.roots[1]"},
+		{`{"principal": {}}`, "principal.dir"},
+		{`{"services": {"webserver": {}}}`, "services.webserver"},
+		{`{"services": {"syncbase": {"statsPrefix": "sb"}}}`, "services.syncbase.statsPrefix"},
+	}
+	for _, test := range tests {
+		_, err := parseConfig("runtime.json", []byte(test.data))
+		cerr, ok := err.(*configError)
+		if !ok {
+			t.Errorf("parsing %s: got error %v, want a *configError", test.data, err)
+			continue
+		}
+		if cerr.field != test.field {
+			t.Errorf("parsing %s: got invalid field %q, want %q", test.data, cerr.field, test.field)
+		}
+	}
+	if _, err := parseConfig("runtime.json", []byte(`{"unknown": {}}`)); err == nil {
+		t.Errorf("expected error for unknown config field")
+	}
+}
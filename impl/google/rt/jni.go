@@ -7,6 +7,9 @@
 package rt
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
 	"unsafe"
 
 	"v.io/v23"
@@ -17,7 +20,6 @@ import (
 	jrpc "v.io/x/jni/impl/google/rpc"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
-	jopts "v.io/x/jni/v23/options"
 	jsecurity "v.io/x/jni/v23/security"
 )
 
@@ -45,13 +47,11 @@ func Init(env jutil.Env) error {
 	return nil
 }
 
-type shutdownKey struct{}
-
 //export Java_io_v_impl_google_rt_VRuntimeImpl_nativeInit
 func Java_io_v_impl_google_rt_VRuntimeImpl_nativeInit(jenv *C.JNIEnv, jRuntime C.jclass, jOptions C.jobject) C.jobject {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	ctx, shutdownFunc := v23.Init()
-	ctx = context.WithValue(ctx, shutdownKey{}, shutdownFunc)
+	ctx = context.WithValue(ctx, shutdownKey{}, newShutdownTracker(shutdownFunc, ctx.Infof))
 	jCtx, err := jcontext.JavaContext(env, ctx, nil)
 	if err != nil {
 		jutil.JThrowV(env, err)
@@ -61,24 +61,83 @@ func Java_io_v_impl_google_rt_VRuntimeImpl_nativeInit(jenv *C.JNIEnv, jRuntime C
 }
 
 //export Java_io_v_impl_google_rt_VRuntimeImpl_nativeShutdown
-func Java_io_v_impl_google_rt_VRuntimeImpl_nativeShutdown(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jCallbackObj C.jobject) {
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeShutdown(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jTimeout C.jobject, jCallbackObj C.jobject) {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCallback := jutil.Object(uintptr(unsafe.Pointer(jCallbackObj)))
 	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
 	if err != nil {
-		jutil.JThrowV(env, err)
+		jutil.CallbackOnFailure(env, jCallback, err)
+		return
+	}
+	var timeout time.Duration
+	if jTimeoutObj := jutil.Object(uintptr(unsafe.Pointer(jTimeout))); !jTimeoutObj.IsNull() {
+		if timeout, err = jutil.GoDuration(env, jTimeoutObj); err != nil {
+			jutil.CallbackOnFailure(env, jCallback, err)
+			return
+		}
 	}
-	value := ctx.Value(shutdownKey{})
-	shutdownFunc, ok := value.(v23.Shutdown)
-	if !ok {
-		panic("shutdown function not found")
+	tracker := getShutdownTracker(ctx)
+	if tracker == nil {
+		jutil.CallbackOnFailure(env, jCallback, errShutdownNotFound)
+		return
 	}
 	jutil.DoAsyncCall(env, jCallback, func() (jutil.Object, error) {
-		shutdownFunc()
-		return jutil.NullObject, nil
+		report := tracker.run(timeout)
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		jReport, err := javaShutdownReport(env, report)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		// Must grab a global reference as we free up the env and all local references that come along
+		// with it.
+		return jutil.NewGlobalRef(env, jReport), nil // Un-refed in DoAsyncCall
 	})
 }
 
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeAddShutdownHook
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeAddShutdownHook(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jName C.jstring, jHookObj C.jobject) {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return
+	}
+	tracker := getShutdownTracker(ctx)
+	if tracker == nil {
+		jutil.JThrowV(env, errShutdownNotFound)
+		return
+	}
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	// Reference Java hook; it will be de-referenced once the hook has been run.
+	jHook := jutil.NewGlobalRef(env, jutil.Object(uintptr(unsafe.Pointer(jHookObj))))
+	run := func() error {
+		env, freeFunc := jutil.GetEnv()
+		defer freeFunc()
+		defer jutil.DeleteGlobalRef(env, jHook)
+		return jutil.CallVoidMethod(env, jHook, "run", nil)
+	}
+	if err := tracker.addHook(name, run); err != nil {
+		jutil.DeleteGlobalRef(env, jHook)
+		jutil.JThrowV(env, err)
+		return
+	}
+}
+
+// javaShutdownReport converts the provided shutdown report into a Java map
+// from component names to VExceptions describing why they failed to stop.
+func javaShutdownReport(env jutil.Env, report map[string]error) (jutil.Object, error) {
+	m := make(map[jutil.Object]jutil.Object)
+	for name, err := range report {
+		jVExp, err := jutil.JVException(env, err)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		m[jutil.JString(env, name)] = jVExp
+	}
+	return jutil.JObjectMap(env, m)
+}
+
 //export Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithNewClient
 func Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithNewClient(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jOptions C.jobject) C.jobject {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
@@ -127,38 +186,52 @@ func Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithNewServer(jenv *C.JNIEnv, j
 		return nil
 	}
 	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
-	d, err := jrpc.GoDispatcher(env, jutil.Object(uintptr(unsafe.Pointer(jDispatcher))))
+	jOpts := jutil.Object(uintptr(unsafe.Pointer(jOptions)))
+	d, err := jrpc.GoDispatcher(env, jutil.Object(uintptr(unsafe.Pointer(jDispatcher))), jOpts)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	opts, err := jopts.GoRpcServerOpts(env, jutil.Object(uintptr(unsafe.Pointer(jOptions))))
+	jServerAttCtx, err := withNewServer(env, ctx, cancel, name, d, jOpts)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	newCtx, server, err := v23.WithNewDispatchingServer(ctx, name, d, opts...)
+	return C.jobject(unsafe.Pointer(jServerAttCtx))
+}
+
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithNewGoServer
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithNewGoServer(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jName C.jstring, jServiceName C.jstring, jOptions C.jobject) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, cancel, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	jServer, err := jrpc.JavaServer(env, server)
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	serviceName := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jServiceName))))
+	d, err := jrpc.NewServiceDispatcher(ctx, serviceName)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	jNewCtx, err := jcontext.JavaContext(env, newCtx, cancel)
+	jServerAttCtx, err := withNewServer(env, ctx, cancel, name, d, jutil.Object(uintptr(unsafe.Pointer(jOptions))))
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	// Explicitly attach a server to the new context.
-	jServerAttCtx, err := jutil.CallStaticObjectMethod(env, jVRuntimeImplClass, "withServer", []jutil.Sign{contextSign, serverSign}, contextSign, jNewCtx, jServer)
+	return C.jobject(unsafe.Pointer(jServerAttCtx))
+}
+
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeGetRegisteredGoServices
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeGetRegisteredGoServices(jenv *C.JNIEnv, jRuntime C.jclass) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	jNames, err := jutil.JStringList(env, jrpc.RegisteredServices())
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	return C.jobject(unsafe.Pointer(jServerAttCtx))
+	return C.jobject(unsafe.Pointer(jNames))
 }
 
 //export Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithPrincipal
@@ -308,3 +381,64 @@ func Java_io_v_impl_google_rt_VRuntimeImpl_nativeNewDiscovery(jenv *C.JNIEnv, jR
 	}
 	return C.jobject(unsafe.Pointer(jDiscovery))
 }
+
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithConfigFile
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithConfigFile(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jPath C.jstring) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	path := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jPath))))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return withConfig(env, jutil.Object(uintptr(unsafe.Pointer(jContext))), path, data)
+}
+
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithConfigData
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeWithConfigData(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject, jName C.jstring, jData C.jbyteArray) C.jobject {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	name := jutil.GoString(env, jutil.Object(uintptr(unsafe.Pointer(jName))))
+	data := jutil.GoByteArray(env, jutil.Object(uintptr(unsafe.Pointer(jData))))
+	return withConfig(env, jutil.Object(uintptr(unsafe.Pointer(jContext))), name, data)
+}
+
+func withConfig(env jutil.Env, jContext jutil.Object, name string, data []byte) C.jobject {
+	ctx, cancel, err := jcontext.GoContext(env, jContext)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	cfg, err := parseConfig(name, data)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	newCtx, err := applyConfig(ctx, cfg)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jNewCtx, err := jcontext.JavaContext(env, newCtx, cancel)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	return C.jobject(unsafe.Pointer(jNewCtx))
+}
+
+//export Java_io_v_impl_google_rt_VRuntimeImpl_nativeGetConfig
+func Java_io_v_impl_google_rt_VRuntimeImpl_nativeGetConfig(jenv *C.JNIEnv, jRuntime C.jclass, jContext C.jobject) C.jstring {
+	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
+	ctx, _, err := jcontext.GoContext(env, jutil.Object(uintptr(unsafe.Pointer(jContext))))
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	data, err := json.MarshalIndent(effectiveConfig(ctx), "", "  ")
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	jConfig := jutil.JString(env, string(data))
+	return C.jstring(unsafe.Pointer(jConfig))
+}
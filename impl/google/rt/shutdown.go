@@ -0,0 +1,206 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+)
+
+type shutdownKey struct{}
+
+var (
+	errShutdownTimeout  = errors.New("did not stop before the shutdown deadline")
+	errShutdownStarted  = errors.New("runtime is shutting down")
+	errShutdownNotFound = errors.New("runtime shutdown state not found in context; was the context created by VRuntimeImpl.init()?")
+)
+
+// shutdownTracker coordinates the orderly shutdown of a runtime.  Shutdown
+// proceeds in three phases, all bound by a single deadline:
+//
+//    1) servers are put into lame-duck mode, in the reverse order of their
+//       creation, and waited on until they are fully stopped,
+//    2) shutdown hooks are run, in the reverse order of their registration,
+//    3) the runtime itself is shut down.
+//
+// A component that fails or doesn't complete before the deadline is recorded
+// in the shutdown report and the next component is processed.  Once the
+// deadline has passed, the remaining components are still asked to stop but
+// are no longer waited on.
+type shutdownTracker struct {
+	shutdown func()
+	logf     func(format string, args ...interface{})
+
+	mu      sync.Mutex
+	started bool
+	servers []trackedServer
+	hooks   []shutdownHook
+
+	once   sync.Once
+	done   chan struct{}
+	report map[string]error
+}
+
+type trackedServer struct {
+	name   string
+	stop   func()
+	closed <-chan struct{}
+}
+
+type shutdownHook struct {
+	name string
+	run  func() error
+}
+
+func newShutdownTracker(shutdown func(), logf func(format string, args ...interface{})) *shutdownTracker {
+	return &shutdownTracker{
+		shutdown: shutdown,
+		logf:     logf,
+		done:     make(chan struct{}),
+	}
+}
+
+// addServer registers a server that should be stopped, by invoking stop,
+// when the runtime is shut down.  The server is considered stopped when the
+// closed channel is closed.
+func (t *shutdownTracker) addServer(name string, stop func(), closed <-chan struct{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started {
+		return errShutdownStarted
+	}
+	t.servers = append(t.servers, trackedServer{name, stop, closed})
+	return nil
+}
+
+// addHook registers a function that should be run when the runtime is shut
+// down, after all servers have been stopped.
+func (t *shutdownTracker) addHook(name string, run func() error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.started {
+		return errShutdownStarted
+	}
+	t.hooks = append(t.hooks, shutdownHook{name, run})
+	return nil
+}
+
+// run shuts the runtime down, giving it at most the provided timeout to do so
+// (zero timeout means no deadline), and returns a report mapping every
+// component that failed to stop cleanly to the reason for the failure.
+//
+// It is safe to call run multiple times: the shutdown is performed only once
+// and all callers wait for it to complete and receive the same report.
+func (t *shutdownTracker) run(timeout time.Duration) map[string]error {
+	t.once.Do(func() {
+		go t.doRun(timeout)
+	})
+	<-t.done
+	return t.report
+}
+
+func (t *shutdownTracker) doRun(timeout time.Duration) {
+	t.mu.Lock()
+	t.started = true
+	servers, hooks := t.servers, t.hooks
+	t.servers, t.hooks = nil, nil
+	t.mu.Unlock()
+
+	expired := make(chan struct{})
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { close(expired) })
+		defer timer.Stop()
+	}
+	report := make(map[string]error)
+	for i := len(servers) - 1; i >= 0; i-- {
+		s := servers[i]
+		t.logf("Shutdown: stopping server %q", s.name)
+		s.stop()
+		if err := waitOrExpire(s.closed, expired); err != nil {
+			t.logf("Shutdown: server %q %v", s.name, err)
+			report[fmt.Sprintf("server %s", s.name)] = err
+		}
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		t.logf("Shutdown: running hook %q", h.name)
+		if err := runOrExpire(h.run, expired); err != nil {
+			t.logf("Shutdown: hook %q failed: %v", h.name, err)
+			report[fmt.Sprintf("hook %s", h.name)] = err
+		}
+	}
+	t.logf("Shutdown: shutting down the runtime")
+	if err := runOrExpire(func() error { t.shutdown(); return nil }, expired); err != nil {
+		t.logf("Shutdown: runtime %v", err)
+		report["runtime"] = err
+	}
+	t.report = report
+	close(t.done)
+}
+
+func waitOrExpire(done <-chan struct{}, expired <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-expired:
+		return errShutdownTimeout
+	}
+}
+
+func runOrExpire(f func() error, expired <-chan struct{}) error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- f()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-expired:
+		// Prefer the result if f completed at the same time as the deadline.
+		select {
+		case err := <-errCh:
+			return err
+		default:
+			return errShutdownTimeout
+		}
+	}
+}
+
+// getShutdownTracker returns the shutdown tracker attached to the provided
+// context, or nil if there isn't one.
+func getShutdownTracker(ctx *context.T) *shutdownTracker {
+	t, _ := ctx.Value(shutdownKey{}).(*shutdownTracker)
+	return t
+}
+
+// TrackServer registers the provided server with the shutdown sequence of the
+// runtime that the given context belongs to.  The stop function must cause
+// the server to enter lame-duck mode (typically by canceling the context the
+// server was created with).
+//
+// If the context isn't attached to a runtime, this function is a no-op.  If
+// the runtime is already shutting down, stop is invoked immediately and an
+// error is returned.
+func TrackServer(ctx *context.T, name string, server rpc.Server, stop func()) error {
+	t := getShutdownTracker(ctx)
+	if t == nil {
+		return nil
+	}
+	if err := t.addServer(name, stop, server.Closed()); err != nil {
+		stop()
+		return err
+	}
+	return nil
+}
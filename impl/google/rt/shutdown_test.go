@@ -0,0 +1,85 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func nopLogf(string, ...interface{}) {}
+
+func TestShutdownOrder(t *testing.T) {
+	var order []string
+	tracker := newShutdownTracker(func() { order = append(order, "runtime") }, nopLogf)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		closed := make(chan struct{})
+		stop := func() {
+			order = append(order, "server "+name)
+			close(closed)
+		}
+		if err := tracker.addServer(name, stop, closed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"x", "y"} {
+		name := name
+		if err := tracker.addHook(name, func() error {
+			order = append(order, "hook "+name)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if report := tracker.run(0); len(report) != 0 {
+		t.Errorf("got report %v, want empty", report)
+	}
+	want := []string{"server b", "server a", "hook y", "hook x", "runtime"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("got order %v, want %v", order, want)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	tracker := newShutdownTracker(func() {}, nopLogf)
+	tracker.addServer("stuck", func() {}, make(chan struct{}))
+	report := tracker.run(50 * time.Millisecond)
+	if got, want := report["server stuck"], errShutdownTimeout; got != want {
+		t.Errorf("got server error %v, want %v", got, want)
+	}
+}
+
+func TestShutdownHookError(t *testing.T) {
+	hookErr := errors.New("hook failed")
+	tracker := newShutdownTracker(func() {}, nopLogf)
+	tracker.addHook("failing", func() error { return hookErr })
+	tracker.addHook("panicking", func() error { panic("boom") })
+	report := tracker.run(0)
+	if got, want := report["hook failing"], hookErr; got != want {
+		t.Errorf("got hook error %v, want %v", got, want)
+	}
+	if _, ok := report["hook panicking"]; !ok {
+		t.Errorf("panicking hook missing from report %v", report)
+	}
+	if err, ok := report["runtime"]; ok {
+		t.Errorf("unexpected runtime error: %v", err)
+	}
+}
+
+func TestShutdownTwice(t *testing.T) {
+	calls := 0
+	tracker := newShutdownTracker(func() { calls++ }, nopLogf)
+	tracker.run(0)
+	tracker.run(0)
+	if calls != 1 {
+		t.Errorf("runtime shut down %d times, want 1", calls)
+	}
+	if err := tracker.addHook("late", func() error { return nil }); err != errShutdownStarted {
+		t.Errorf("got error %v, want %v", err, errShutdownStarted)
+	}
+}
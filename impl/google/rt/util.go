@@ -0,0 +1,181 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build java android
+
+package rt
+
+import (
+	"os"
+	"sync"
+
+	"v.io/v23"
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/x/lib/vlog"
+	vsecurity "v.io/x/ref/lib/security"
+
+	jrpc "v.io/x/jni/impl/google/rpc"
+	jutil "v.io/x/jni/util"
+	jcontext "v.io/x/jni/v23/context"
+	jopts "v.io/x/jni/v23/options"
+)
+
+// #include "jni.h"
+import "C"
+
+// appliedLogging is the logging config that was last applied.  Unlike the
+// other settings, logging is configured for the whole process.
+var appliedLogging struct {
+	sync.Mutex
+	cfg *loggingConfig
+}
+
+// applyConfig returns a new context derived from the provided context, with
+// all of the settings in the provided config applied.
+func applyConfig(ctx *context.T, cfg *runtimeConfig) (*context.T, error) {
+	applied := &appliedConfig{cfg: cfg}
+	if prev, ok := ctx.Value(configKey{}).(*appliedConfig); ok {
+		applied.cfg = prev.cfg.merge(cfg)
+		applied.principal = prev.principal
+	}
+	if l := cfg.Logging; l != nil {
+		if err := vlog.Log.Configure(vlog.OverridePriorConfiguration(true), vlog.LogDir(l.Dir), vlog.LogToStderr(l.ToStderr), vlog.Level(l.Level), l.vmodule); err != nil {
+			return nil, err
+		}
+		appliedLogging.Lock()
+		appliedLogging.cfg = l
+		appliedLogging.Unlock()
+	}
+	if p := cfg.Principal; p != nil {
+		principal, err := vsecurity.LoadPersistentPrincipal(p.Dir, nil)
+		if os.IsNotExist(err) {
+			principal, err = vsecurity.CreatePersistentPrincipal(p.Dir, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ctx, err = v23.WithPrincipal(ctx, principal); err != nil {
+			return nil, err
+		}
+		applied.principal = principal
+	}
+	if s := cfg.ListenSpec; s != nil {
+		spec := v23.GetListenSpec(ctx)
+		if len(s.Addrs) > 0 {
+			spec.Addrs = make(rpc.ListenAddrs, len(s.Addrs))
+			for i, addr := range s.Addrs {
+				spec.Addrs[i].Protocol = addr.Protocol
+				spec.Addrs[i].Address = addr.Address
+			}
+		}
+		spec.Proxy = s.Proxy
+		ctx = v23.WithListenSpec(ctx, spec)
+	}
+	if n := cfg.Namespace; n != nil {
+		var err error
+		if ctx, _, err = v23.WithNewNamespace(ctx, n.Roots...); err != nil {
+			return nil, err
+		}
+	}
+	return context.WithValue(ctx, configKey{}, applied), nil
+}
+
+// effectiveConfig returns the configuration in effect for the provided
+// context.  The listen spec and namespace roots reflect the current runtime
+// state, the logging section is the one last applied to the process, the
+// principal section is reported only if the context's principal is still the
+// one it configured, and the services section merges all of the configs
+// applied to the context.
+func effectiveConfig(ctx *context.T) *runtimeConfig {
+	var cfg runtimeConfig
+	applied, ok := ctx.Value(configKey{}).(*appliedConfig)
+	if ok {
+		cfg.Services = applied.cfg.Services
+		if applied.principal != nil && v23.GetPrincipal(ctx) == applied.principal {
+			cfg.Principal = applied.cfg.Principal
+		}
+	}
+	appliedLogging.Lock()
+	cfg.Logging = appliedLogging.cfg
+	appliedLogging.Unlock()
+	spec := v23.GetListenSpec(ctx)
+	cfg.ListenSpec = &listenSpecConfig{Proxy: spec.Proxy}
+	for _, addr := range spec.Addrs {
+		cfg.ListenSpec.Addrs = append(cfg.ListenSpec.Addrs, listenAddrConfig{addr.Protocol, addr.Address})
+	}
+	if ns := v23.GetNamespace(ctx); ns != nil {
+		cfg.Namespace = &namespaceConfig{Roots: ns.Roots()}
+	}
+	return &cfg
+}
+
+// withNewServer creates a new server with the provided name and dispatcher,
+// configured by the provided Java RpcServerOptions, and returns a Java context
+// derived from ctx that has the server attached to it.  Go services requested
+// by the options are mounted alongside the provided dispatcher, and all
+// requests are run through the interceptors requested by the options.  If the
+// options request it, the server also serves a health service, which bypasses
+// the interceptors.
+func withNewServer(env jutil.Env, ctx *context.T, cancel func(), name string, d rpc.Dispatcher, jOptions jutil.Object) (jutil.Object, error) {
+	opts, err := jopts.GoRpcServerOpts(env, jOptions)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	publishName, err := jopts.GoRpcServerPublishName(env, jOptions, name)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	mounts, err := jopts.GoRpcServerServiceMounts(env, jOptions)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	if len(mounts) > 0 {
+		if d, err = jrpc.NewCompositeDispatcher(ctx, d, mounts); err != nil {
+			return jutil.NullObject, err
+		}
+	}
+	interceptorOpts, err := jopts.GoRpcServerInterceptorOpts(env, jOptions)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	d = jrpc.Intercept(d, jrpc.ServerInterceptors(interceptorOpts)...)
+	healthCheck, err := jutil.JBoolField(env, jOptions, "healthCheck")
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	var health *jrpc.Health
+	if healthCheck {
+		health = jrpc.NewHealth(true)
+		healthAuth, err := jopts.GoRpcServerHealthAuthorizer(env, jOptions)
+		if err != nil {
+			return jutil.NullObject, err
+		}
+		if d, err = health.Dispatcher(d, healthAuth); err != nil {
+			return jutil.NullObject, err
+		}
+	}
+	// Derive a separate context for the server so that the runtime shutdown
+	// can lame-duck it independently of the caller's context.
+	serverCtx, serverCancel := context.WithCancel(ctx)
+	newCtx, server, err := v23.WithNewDispatchingServer(serverCtx, publishName, d, opts...)
+	if err != nil {
+		serverCancel()
+		return jutil.NullObject, err
+	}
+	if err := TrackServer(ctx, name, server, serverCancel); err != nil {
+		return jutil.NullObject, err
+	}
+	health.Attach(server)
+	jServer, err := jrpc.JavaServer(env, server)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	jNewCtx, err := jcontext.JavaContext(env, newCtx, cancel)
+	if err != nil {
+		return jutil.NullObject, err
+	}
+	// Explicitly attach a server to the new context.
+	return jutil.CallStaticObjectMethod(env, jVRuntimeImplClass, "withServer", []jutil.Sign{contextSign, serverSign}, contextSign, jNewCtx, jServer)
+}
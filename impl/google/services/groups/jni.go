@@ -12,9 +12,11 @@ import (
 	"unsafe"
 
 	"v.io/v23"
+	"v.io/v23/context"
 	"v.io/x/ref/services/groups/lib"
 
 	jrpc "v.io/x/jni/impl/google/rpc"
+	jrt "v.io/x/jni/impl/google/rt"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
 )
@@ -47,6 +49,11 @@ func Java_io_v_impl_google_services_groups_GroupServer_nativeWithNewServer(jenv
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCtx := jutil.Object(uintptr(unsafe.Pointer(jContext)))
 	jParams := jutil.Object(uintptr(unsafe.Pointer(jGroupServerParams)))
+	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
 
 	// Read and translate all of the server params.
 	name, err := jutil.CallStringMethod(env, jParams, "getName", nil)
@@ -59,6 +66,9 @@ func Java_io_v_impl_google_services_groups_GroupServer_nativeWithNewServer(jenv
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	// Params that aren't set default to the ones in the runtime config.
+	params := jrt.ServiceParams(ctx, "groups", jrt.ServiceConfig{Name: name, StorageRootDir: rootDir})
+	name, rootDir = params.Name, params.StorageRootDir
 	if rootDir == "" {
 		rootDir = filepath.Join(os.TempDir(), "groupserver")
 		if err := os.Mkdir(rootDir, 0755); err != nil && !os.IsExist(err) {
@@ -77,22 +87,38 @@ func Java_io_v_impl_google_services_groups_GroupServer_nativeWithNewServer(jenv
 		return nil
 	}
 
-	// Start the server.
-	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	healthCheck, err := jutil.CallBooleanMethod(env, jParams, "getHealthCheck", nil)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
+
+	// Start the server.
 	dispatcher, err := lib.NewGroupsDispatcher(rootDir, engine)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	newCtx, s, err := v23.WithNewDispatchingServer(ctx, name, dispatcher)
+	var health *jrpc.Health
+	if healthCheck {
+		health = jrpc.NewHealth(true)
+		if dispatcher, err = health.Dispatcher(dispatcher, nil); err != nil {
+			jutil.JThrowV(env, err)
+			return nil
+		}
+	}
+	serverCtx, serverCancel := context.WithCancel(ctx)
+	newCtx, s, err := v23.WithNewDispatchingServer(serverCtx, name, dispatcher)
 	if err != nil {
+		serverCancel()
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	if err := jrt.TrackServer(ctx, name, s, serverCancel); err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	health.Attach(s)
 	jNewCtx, err := jcontext.JavaContext(env, newCtx, cancel)
 	if err != nil {
 		jutil.JThrowV(env, err)
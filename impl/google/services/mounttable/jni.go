@@ -14,11 +14,13 @@ import (
 	"unsafe"
 
 	"v.io/v23"
+	"v.io/v23/context"
 	"v.io/v23/security/access"
 	"v.io/x/ref/services/mounttable/mounttablelib"
 
 	"v.io/v23/options"
 	jrpc "v.io/x/jni/impl/google/rpc"
+	jrt "v.io/x/jni/impl/google/rt"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
 	jaccess "v.io/x/jni/v23/security/access"
@@ -52,6 +54,11 @@ func Java_io_v_impl_google_services_mounttable_MountTableServer_nativeWithNewSer
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCtx := jutil.Object(uintptr(unsafe.Pointer(jContext)))
 	jParams := jutil.Object(uintptr(unsafe.Pointer(jMountTableServerParams)))
+	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
 
 	// Read and translate all of the server params.
 	mountName, err := jutil.CallStringMethod(env, jParams, "getName", nil)
@@ -64,6 +71,14 @@ func Java_io_v_impl_google_services_mounttable_MountTableServer_nativeWithNewSer
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	statsPrefix, err := jutil.CallStringMethod(env, jParams, "getStatsPrefix", nil)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	// Params that aren't set default to the ones in the runtime config.
+	params := jrt.ServiceParams(ctx, "mounttable", jrt.ServiceConfig{Name: mountName, StorageRootDir: rootDir, StatsPrefix: statsPrefix})
+	mountName, rootDir, statsPrefix = params.Name, params.StorageRootDir, params.StatsPrefix
 	permsJMap, err := jutil.CallMapMethod(env, jParams, "getPermissions", nil)
 	if err != nil {
 		jutil.JThrowV(env, err)
@@ -99,28 +114,38 @@ func Java_io_v_impl_google_services_mounttable_MountTableServer_nativeWithNewSer
 	if err := w.Flush(); err != nil {
 		jutil.JThrowV(env, fmt.Errorf("Couldn't flush to permissions file: %v", err))
 	}
-	statsPrefix, err := jutil.CallStringMethod(env, jParams, "getStatsPrefix", nil)
+	healthCheck, err := jutil.CallBooleanMethod(env, jParams, "getHealthCheck", nil)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
 
 	// Start the mounttable server.
-	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	d, err := mounttablelib.NewMountTableDispatcher(ctx, permsFile.Name(), rootDir, statsPrefix)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	d, err := mounttablelib.NewMountTableDispatcher(ctx, permsFile.Name(), rootDir, statsPrefix)
+	var health *jrpc.Health
+	if healthCheck {
+		health = jrpc.NewHealth(true)
+		if d, err = health.Dispatcher(d, nil); err != nil {
+			jutil.JThrowV(env, err)
+			return nil
+		}
+	}
+	serverCtx, serverCancel := context.WithCancel(ctx)
+	newCtx, s, err := v23.WithNewDispatchingServer(serverCtx, mountName, d, options.ServesMountTable(true))
 	if err != nil {
+		serverCancel()
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	newCtx, s, err := v23.WithNewDispatchingServer(ctx, mountName, d, options.ServesMountTable(true))
-	if err != nil {
+	if err := jrt.TrackServer(ctx, mountName, s, serverCancel); err != nil {
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	health.Attach(s)
 	jNewCtx, err := jcontext.JavaContext(env, newCtx, cancel)
 	if err != nil {
 		jutil.JThrowV(env, err)
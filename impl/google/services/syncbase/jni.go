@@ -12,7 +12,9 @@ import (
 	"unsafe"
 
 	"v.io/v23"
+	"v.io/v23/context"
 	"v.io/v23/options"
+	"v.io/v23/rpc"
 	wire "v.io/v23/services/syncbase"
 	"v.io/x/ref/lib/dispatcher"
 	"v.io/x/ref/services/syncbase/discovery"
@@ -20,6 +22,7 @@ import (
 	"v.io/x/ref/services/syncbase/vsync"
 
 	jrpc "v.io/x/jni/impl/google/rpc"
+	jrt "v.io/x/jni/impl/google/rt"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
 	jaccess "v.io/x/jni/v23/security/access"
@@ -61,6 +64,11 @@ func Java_io_v_impl_google_services_syncbase_SyncbaseServer_nativeWithNewServer(
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
 	jCtx := jutil.Object(uintptr(unsafe.Pointer(jContext)))
 	jParams := jutil.Object(uintptr(unsafe.Pointer(jSyncbaseServerParams)))
+	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	if err != nil {
+		jutil.JThrowV(env, err)
+		return nil
+	}
 
 	// Read and translate all of the server params.
 	jPerms, err := jutil.CallObjectMethod(env, jParams, "getPermissions", nil, permissionsSign)
@@ -83,6 +91,9 @@ func Java_io_v_impl_google_services_syncbase_SyncbaseServer_nativeWithNewServer(
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	// Params that aren't set default to the ones in the runtime config.
+	params := jrt.ServiceParams(ctx, "syncbase", jrt.ServiceConfig{Name: name, StorageRootDir: rootDir})
+	name, rootDir = params.Name, params.StorageRootDir
 	if rootDir == "" {
 		rootDir = filepath.Join(os.TempDir(), "syncbaseserver")
 		if err := os.Mkdir(rootDir, 0755); err != nil && !os.IsExist(err) {
@@ -100,7 +111,7 @@ func Java_io_v_impl_google_services_syncbase_SyncbaseServer_nativeWithNewServer(
 		jutil.JThrowV(env, err)
 		return nil
 	}
-	ctx, cancel, err := jcontext.GoContext(env, jCtx)
+	healthCheck, err := jutil.CallBooleanMethod(env, jParams, "getHealthCheck", nil)
 	if err != nil {
 		jutil.JThrowV(env, err)
 		return nil
@@ -110,12 +121,32 @@ func Java_io_v_impl_google_services_syncbase_SyncbaseServer_nativeWithNewServer(
 	// clients in the service and the rpc server. (i.e. connections are shared if the
 	// context returned from WithNewDispatchingServer is used for client calls).
 	d := dispatcher.NewDispatcherWrapper()
-	ctx, s, err := v23.WithNewDispatchingServer(ctx, name, d, options.ChannelTimeout(vsync.NeighborConnectionTimeout))
+	var serverDispatcher rpc.Dispatcher = d
+	// The server becomes ready once the service is created.
+	var health *jrpc.Health
+	if healthCheck {
+		health = jrpc.NewHealth(false)
+		if serverDispatcher, err = health.Dispatcher(d, nil); err != nil {
+			cancel()
+			jutil.JThrowV(env, err)
+			return nil
+		}
+	}
+	serverCtx, serverCancel := context.WithCancel(ctx)
+	newCtx, s, err := v23.WithNewDispatchingServer(serverCtx, name, serverDispatcher, options.ChannelTimeout(vsync.NeighborConnectionTimeout))
 	if err != nil {
+		serverCancel()
 		cancel()
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	if err := jrt.TrackServer(ctx, name, s, serverCancel); err != nil {
+		cancel()
+		jutil.JThrowV(env, err)
+		return nil
+	}
+	health.Attach(s)
+	ctx = newCtx
 
 	service, err := server.NewService(ctx, server.ServiceOptions{
 		Perms:   perms,
@@ -136,6 +167,9 @@ func Java_io_v_impl_google_services_syncbase_SyncbaseServer_nativeWithNewServer(
 		jutil.JThrowV(env, err)
 		return nil
 	}
+	if health != nil {
+		health.SetReady(true)
+	}
 	jNewCtx, err := jcontext.JavaContext(env, ctx, cancel)
 	if err != nil {
 		jutil.JThrowV(env, err)
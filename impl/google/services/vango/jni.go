@@ -12,6 +12,9 @@ import (
 	"runtime"
 	"unsafe"
 
+	"v.io/v23/security"
+
+	jrpc "v.io/x/jni/impl/google/rpc"
 	jutil "v.io/x/jni/util"
 	jcontext "v.io/x/jni/v23/context"
 )
@@ -19,6 +22,13 @@ import (
 // #include "jni.h"
 import "C"
 
+func init() {
+	// Let Java serve the echo service, e.g., via VRuntime.withNewGoServer().
+	if err := jrpc.RegisterService("vango/echo", jrpc.ObjectFactory(&echoServer{}, security.AllowEveryone())); err != nil {
+		panic(err)
+	}
+}
+
 //export Java_io_v_android_util_Vango_nativeGoContextCall
 func Java_io_v_android_util_Vango_nativeGoContextCall(jenv *C.JNIEnv, jVango C.jobject, jContext C.jobject, jKey C.jstring, jOutput C.jobject) {
 	env := jutil.Env(uintptr(unsafe.Pointer(jenv)))
@@ -281,6 +281,17 @@ func CallStaticIntMethod(env Env, class Class, name string, argSigns []Sign, arg
 	return ret, JExceptionMsg(env)
 }
 
+// CallStaticBooleanMethod calls a static Java method that returns a boolean.
+func CallStaticBooleanMethod(env Env, class Class, name string, argSigns []Sign, args ...interface{}) (bool, error) {
+	jmid, jArgArr, freeFunc, err := setupStaticMethodCall(env, class, name, argSigns, BoolSign, args...)
+	if err != nil {
+		return false, err
+	}
+	defer freeFunc()
+	ret := C.CallStaticBooleanMethodA(env.value(), class.value(), jmid, jArgArr) != C.JNI_FALSE
+	return ret, JExceptionMsg(env)
+}
+
 // CallStaticVoidMethod calls a static Java method doesn't return anything.
 func CallStaticVoidMethod(env Env, class Class, name string, argSigns []Sign, args ...interface{}) error {
 	jmid, jArgArr, freeFunc, err := setupStaticMethodCall(env, class, name, argSigns, VoidSign, args...)
@@ -86,6 +86,10 @@ jlong GetLongField(JNIEnv* env, jobject obj, jfieldID fieldID) {
   return (*env)->GetLongField(env, obj, fieldID);
 }
 
+jdouble GetDoubleField(JNIEnv* env, jobject obj, jfieldID fieldID) {
+  return (*env)->GetDoubleField(env, obj, fieldID);
+}
+
 jobject GetStaticObjectField(JNIEnv* env, jclass cls, jfieldID fieldID) {
   return (*env)->GetStaticObjectField(env, cls, fieldID);
 }
@@ -267,6 +267,16 @@ func JLongField(env Env, obj Object, field string) (int64, error) {
 	return int64(C.GetLongField(env.value(), obj.value(), fid)), nil
 }
 
+// JDoubleField returns the value of the provided Java object's double field, or
+// error if the field value couldn't be retrieved.
+func JDoubleField(env Env, obj Object, field string) (float64, error) {
+	fid, err := jFieldID(env, GetClass(env, obj), field, DoubleSign)
+	if err != nil {
+		return -1, err
+	}
+	return float64(C.GetDoubleField(env.value(), obj.value(), fid)), nil
+}
+
 // JStringField returns the value of the provided Java object's String field, or
 // error if the field value couldn't be retrieved.
 func JStringField(env Env, obj Object, field string) (string, error) {
@@ -0,0 +1,52 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"fmt"
+
+	"v.io/v23/context"
+	"v.io/v23/security"
+)
+
+// serverAuthorizer returns the authorizer of the servers called with the
+// provided server authorizer and allowed server patterns, either of which may
+// be unset (i.e., nil).  If both are set, servers must satisfy both.  Returns
+// nil if neither is set.
+func serverAuthorizer(auth security.Authorizer, allowed []security.BlessingPattern) security.Authorizer {
+	switch {
+	case allowed == nil:
+		return auth
+	case auth == nil:
+		return allowedServersAuthorizer(allowed)
+	}
+	return allAuthorizers{auth, allowedServersAuthorizer(allowed)}
+}
+
+// allowedServersAuthorizer authorizes servers that present blessings matched
+// by at least one of the allowed patterns.
+type allowedServersAuthorizer []security.BlessingPattern
+
+func (a allowedServersAuthorizer) Authorize(ctx *context.T, call security.Call) error {
+	names, rejected := security.RemoteBlessingNames(ctx, call)
+	for _, pattern := range a {
+		if pattern.MatchedBy(names...) {
+			return nil
+		}
+	}
+	return fmt.Errorf("server blessings %v (rejected: %v) don't match any of the allowed patterns %v", names, rejected, []security.BlessingPattern(a))
+}
+
+// allAuthorizers authorizes calls that all of its authorizers authorize.
+type allAuthorizers []security.Authorizer
+
+func (a allAuthorizers) Authorize(ctx *context.T, call security.Call) error {
+	for _, auth := range a {
+		if err := auth.Authorize(ctx, call); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"v.io/v23/context"
+	"v.io/v23/security"
+)
+
+// testAuthorizer authorizes calls iff it is allowed to, and counts the calls
+// it authorized.
+type testAuthorizer struct {
+	allow bool
+	calls int
+}
+
+func (a *testAuthorizer) Authorize(*context.T, security.Call) error {
+	a.calls++
+	if !a.allow {
+		return errors.New("not authorized")
+	}
+	return nil
+}
+
+func TestServerAuthorizer(t *testing.T) {
+	auth := &testAuthorizer{allow: true}
+	allowed := []security.BlessingPattern{"root:alice"}
+	tests := []struct {
+		auth    security.Authorizer
+		allowed []security.BlessingPattern
+		want    security.Authorizer
+	}{
+		{nil, nil, nil},
+		{auth, nil, auth},
+		{nil, allowed, allowedServersAuthorizer(allowed)},
+		{auth, allowed, allAuthorizers{auth, allowedServersAuthorizer(allowed)}},
+	}
+	for _, test := range tests {
+		if got := serverAuthorizer(test.auth, test.allowed); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("serverAuthorizer(%v, %v): got %#v, want %#v", test.auth, test.allowed, got, test.want)
+		}
+	}
+}
+
+func TestAllAuthorizers(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+	call := security.NewCall(&security.CallParams{Method: "Get"})
+	tests := []struct {
+		first, second bool
+		authorized    bool
+	}{
+		{true, true, true},
+		{true, false, false},
+		{false, true, false},
+		{false, false, false},
+	}
+	for _, test := range tests {
+		first, second := &testAuthorizer{allow: test.first}, &testAuthorizer{allow: test.second}
+		err := allAuthorizers{first, second}.Authorize(ctx, call)
+		if got := err == nil; got != test.authorized {
+			t.Errorf("authorizers allowing %v and %v: got authorized %v, want %v", test.first, test.second, got, test.authorized)
+		}
+		if first.calls != 1 {
+			t.Errorf("first authorizer was called %d times, want 1", first.calls)
+		}
+	}
+
+	// Servers presenting no blessings matched by the allowed patterns are
+	// rejected, even if the server authorizer allows them.
+	auth := serverAuthorizer(&testAuthorizer{allow: true}, []security.BlessingPattern{"root:alice"})
+	if err := auth.Authorize(ctx, call); err == nil {
+		t.Errorf("server without allowed blessings was authorized")
+	}
+}
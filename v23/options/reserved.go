@@ -0,0 +1,35 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"v.io/v23/context"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+	"v.io/v23/verror"
+	"v.io/x/ref/services/debug/debuglib"
+)
+
+// reservedNameDispatcher returns the dispatcher that should serve the reserved
+// (i.e., "__debug") portion of a server's namespace, or nil if the runtime's
+// default dispatcher should be used.  Debug services are either disabled, or
+// served with the provided authorizer if it is non-nil.
+func reservedNameDispatcher(disableDebug bool, debugAuth security.Authorizer) rpc.Dispatcher {
+	if disableDebug {
+		return noDebugDispatcher{}
+	}
+	if debugAuth != nil {
+		return debuglib.NewDispatcher(debugAuth)
+	}
+	return nil
+}
+
+// noDebugDispatcher is a reserved-name dispatcher that refuses all lookups,
+// i.e., doesn't serve any debug services.
+type noDebugDispatcher struct{}
+
+func (noDebugDispatcher) Lookup(ctx *context.T, suffix string) (interface{}, security.Authorizer, error) {
+	return nil, nil, verror.New(verror.ErrNoExist, ctx, suffix)
+}
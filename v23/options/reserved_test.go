@@ -0,0 +1,60 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"errors"
+	"testing"
+
+	"v.io/v23/context"
+	"v.io/v23/security"
+)
+
+// debugAuthorizer is the authorizer that guards the debug services.
+type debugAuthorizer struct{}
+
+func (debugAuthorizer) Authorize(*context.T, security.Call) error {
+	return errors.New("not a debugger")
+}
+
+func TestReservedNameDispatcher(t *testing.T) {
+	ctx, cancel := context.RootContext()
+	defer cancel()
+
+	if d := reservedNameDispatcher(false, nil); d != nil {
+		t.Errorf("got dispatcher %v, want the runtime's default", d)
+	}
+
+	// With debugging disabled, all of the debug services are refused, even
+	// if there is a debug authorizer.
+	for _, auth := range []security.Authorizer{nil, debugAuthorizer{}} {
+		d := reservedNameDispatcher(true, auth)
+		for _, suffix := range []string{"debug", "debug/stats/system", "debug/logs"} {
+			if obj, _, err := d.Lookup(ctx, suffix); obj != nil || err == nil {
+				t.Errorf("lookup of %q with debugging disabled succeeded: %v", suffix, obj)
+			}
+		}
+	}
+
+	// The debug authorizer guards the debug services, not the server's
+	// authorizer.
+	d := reservedNameDispatcher(false, debugAuthorizer{})
+	if d == nil {
+		t.Fatal("got the runtime's default dispatcher, want one with the debug authorizer")
+	}
+	for _, suffix := range []string{"debug", "debug/stats/system"} {
+		obj, auth, err := d.Lookup(ctx, suffix)
+		if err != nil {
+			t.Errorf("lookup of %q failed: %v", suffix, err)
+			continue
+		}
+		if obj == nil {
+			t.Errorf("lookup of %q returned no service", suffix)
+		}
+		if _, ok := auth.(debugAuthorizer); !ok {
+			t.Errorf("lookup of %q returned authorizer %v, want the debug authorizer", suffix, auth)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"time"
+
+	"v.io/v23/rpc"
+	"v.io/v23/verror"
+)
+
+// RetryPolicy is a per-call option that makes the JNI client retry starting
+// a call that failed with a retryable error, waiting with an exponential
+// backoff between attempts.
+//
+// Unlike the other call options, RetryPolicy isn't understood by the Go
+// client; it must be removed from the options with SplitRetryPolicy before
+// they are handed to rpc.Client.StartCall.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the time to wait before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the time to wait between any two attempts.  Zero means
+	// no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after each retry.
+	// Values smaller than 1 are treated as 1.
+	Multiplier float64
+}
+
+func (RetryPolicy) RPCCallOpt() {}
+
+// Backoff returns the time to wait before the given retry (numbered from 1).
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		backoff *= mult
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// Retryable returns true iff an attempt that failed with the provided error
+// should be retried.
+func (p RetryPolicy) Retryable(err error) bool {
+	return verror.Action(err) != verror.NoRetry
+}
+
+// SplitRetryPolicy removes the RetryPolicy option (if any) from the
+// provided options, returning it along with the remaining options.
+func SplitRetryPolicy(opts []rpc.CallOpt) (*RetryPolicy, []rpc.CallOpt) {
+	var policy *RetryPolicy
+	rest := make([]rpc.CallOpt, 0, len(opts))
+	for _, opt := range opts {
+		if p, ok := opt.(RetryPolicy); ok {
+			policy = &p
+			continue
+		}
+		rest = append(rest, opt)
+	}
+	return policy, rest
+}
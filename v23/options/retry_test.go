@@ -0,0 +1,38 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     3,
+	}
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 300 * time.Millisecond},
+		{3, 900 * time.Millisecond},
+		{4, time.Second},
+		{10, time.Second},
+	}
+	for _, test := range tests {
+		if got := p.Backoff(test.retry); got != test.want {
+			t.Errorf("Backoff(%d): got %v, want %v", test.retry, got, test.want)
+		}
+	}
+	constant := RetryPolicy{InitialBackoff: 50 * time.Millisecond}
+	if got, want := constant.Backoff(4), 50*time.Millisecond; got != want {
+		t.Errorf("Backoff(4) with no multiplier: got %v, want %v", got, want)
+	}
+}
@@ -7,8 +7,10 @@
 package options
 
 import (
+	"runtime"
 	"time"
 
+	"v.io/v23/context"
 	"v.io/v23/naming"
 	"v.io/v23/options"
 	"v.io/v23/rpc"
@@ -16,6 +18,7 @@ import (
 
 	jnamespace "v.io/x/jni/impl/google/namespace"
 	jutil "v.io/x/jni/util"
+	jcontext "v.io/x/jni/v23/context"
 	jsecurity "v.io/x/jni/v23/security"
 )
 
@@ -23,8 +26,15 @@ import (
 import "C"
 
 var (
-	authorizerSign = jutil.ClassSign("io.v.v23.security.Authorizer")
-	mountEntrySign = jutil.ClassSign("io.v.v23.naming.MountEntry")
+	authorizerSign      = jutil.ClassSign("io.v.v23.security.Authorizer")
+	mountEntrySign      = jutil.ClassSign("io.v.v23.naming.MountEntry")
+	blessingsSign       = jutil.ClassSign("io.v.v23.security.Blessings")
+	blessingPatternSign = jutil.ClassSign("io.v.v23.security.BlessingPattern")
+	contextSign         = jutil.ClassSign("io.v.v23.context.VContext")
+	callSign            = jutil.ClassSign("io.v.v23.security.Call")
+	granterSign         = jutil.ClassSign("io.v.v23.rpc.Granter")
+	publicKeySign       = jutil.ClassSign("java.security.interfaces.ECPublicKey")
+	retryPolicySign     = jutil.ClassSign("io.v.v23.rpc.RetryPolicy")
 )
 
 func getAuthorizer(env jutil.Env, obj jutil.Object, field string) (security.Authorizer, error) {
@@ -75,6 +85,178 @@ func getDuration(env jutil.Env, obj jutil.Object, field string) (*time.Duration,
 	return nil, nil
 }
 
+func getBlessings(env jutil.Env, obj jutil.Object, field string) (*security.Blessings, error) {
+	jBlessings, err := jutil.JObjectField(env, obj, field, blessingsSign)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jBlessings.IsNull() {
+		blessings, err := jsecurity.GoBlessings(env, jBlessings)
+		if err != nil {
+			return nil, err
+		}
+		return &blessings, nil
+	}
+	return nil, nil
+}
+
+func getBlessingPatterns(env jutil.Env, obj jutil.Object, field string) ([]security.BlessingPattern, error) {
+	jPatterns, err := jutil.JObjectField(env, obj, field, jutil.ArraySign(blessingPatternSign))
+	if err != nil {
+		return nil, err
+	}
+
+	if !jPatterns.IsNull() {
+		patternarr, err := jutil.GoObjectArray(env, jPatterns)
+		if err != nil {
+			return nil, err
+		}
+		patterns := make([]security.BlessingPattern, len(patternarr))
+		for i, jPattern := range patternarr {
+			if patterns[i], err = jsecurity.GoBlessingPattern(env, jPattern); err != nil {
+				return nil, err
+			}
+		}
+		return patterns, nil
+	}
+	return nil, nil
+}
+
+func getGranter(env jutil.Env, obj jutil.Object) (rpc.Granter, error) {
+	jGranter, err := jutil.JObjectField(env, obj, "granter", granterSign)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jGranter.IsNull() {
+		// Reference Java granter; it will be de-referenced when the Go granter
+		// created below is garbage-collected (through the finalizer callback we
+		// setup just below).
+		g := &javaGranter{jutil.NewGlobalRef(env, jGranter)}
+		runtime.SetFinalizer(g, func(g *javaGranter) {
+			env, freeFunc := jutil.GetEnv()
+			defer freeFunc()
+			jutil.DeleteGlobalRef(env, g.jGranter)
+		})
+		return g, nil
+	}
+	return nil, nil
+}
+
+// javaGranter is an rpc.Granter that asks a Java Granter object for the
+// blessings to grant to the server.
+type javaGranter struct {
+	jGranter jutil.Object
+}
+
+func (g *javaGranter) Grant(ctx *context.T, call security.Call) (security.Blessings, error) {
+	env, freeFunc := jutil.GetEnv()
+	defer freeFunc()
+	jContext, err := jcontext.JavaContext(env, ctx, nil)
+	if err != nil {
+		return security.Blessings{}, err
+	}
+	jCall, err := jsecurity.JavaCall(env, call)
+	if err != nil {
+		return security.Blessings{}, err
+	}
+	jBlessings, err := jutil.CallObjectMethod(env, g.jGranter, "grant", []jutil.Sign{contextSign, callSign}, blessingsSign, jContext, jCall)
+	if err != nil {
+		return security.Blessings{}, err
+	}
+	return jsecurity.GoBlessings(env, jBlessings)
+}
+
+func (*javaGranter) RPCCallOpt() {}
+
+func getPublicKey(env jutil.Env, obj jutil.Object, field string) (security.PublicKey, error) {
+	jKey, err := jutil.JObjectField(env, obj, field, publicKeySign)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jKey.IsNull() {
+		return jsecurity.GoPublicKey(env, jKey)
+	}
+	return nil, nil
+}
+
+func getRetryPolicy(env jutil.Env, obj jutil.Object) (*RetryPolicy, error) {
+	jPolicy, err := jutil.JObjectField(env, obj, "retryPolicy", retryPolicySign)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jPolicy.IsNull() {
+		var policy RetryPolicy
+		if policy.MaxAttempts, err = jutil.JIntField(env, jPolicy, "maxAttempts"); err != nil {
+			return nil, err
+		}
+		if backoff, err := getDuration(env, jPolicy, "initialBackoff"); err != nil {
+			return nil, err
+		} else if backoff != nil {
+			policy.InitialBackoff = *backoff
+		}
+		if backoff, err := getDuration(env, jPolicy, "maxBackoff"); err != nil {
+			return nil, err
+		} else if backoff != nil {
+			policy.MaxBackoff = *backoff
+		}
+		if policy.Multiplier, err = jutil.JDoubleField(env, jPolicy, "multiplier"); err != nil {
+			return nil, err
+		}
+		return &policy, nil
+	}
+	return nil, nil
+}
+
+// getMethodLimits returns the Java map from method names to Integer limits
+// stored in the provided field, or nil if the field is null.
+func getMethodLimits(env jutil.Env, obj jutil.Object, field string) (map[string]int, error) {
+	jLimits, err := jutil.JObjectField(env, obj, field, jutil.MapSign)
+	if err != nil {
+		return nil, err
+	}
+
+	if !jLimits.IsNull() {
+		m, err := jutil.GoObjectMap(env, jLimits)
+		if err != nil {
+			return nil, err
+		}
+		limits := make(map[string]int, len(m))
+		for jMethod, jLimit := range m {
+			limit, err := jutil.CallIntMethod(env, jLimit, "intValue", nil)
+			if err != nil {
+				return nil, err
+			}
+			limits[jutil.GoString(env, jMethod)] = limit
+		}
+		return limits, nil
+	}
+	return nil, nil
+}
+
+// getReservedNameDispatcher returns the dispatcher that should serve the
+// reserved portion of the server's namespace, as configured by the Java
+// RpcServerOptions, or nil if the runtime's default dispatcher should be used.
+func getReservedNameDispatcher(env jutil.Env, obj jutil.Object) (rpc.Dispatcher, error) {
+	disable, err := jutil.JBoolField(env, obj, "disableDebugDispatcher")
+	if err != nil {
+		return nil, err
+	}
+	auth, err := getAuthorizer(env, obj, "debugAuthorizer")
+	if err != nil {
+		return nil, err
+	}
+	return reservedNameDispatcher(disable, auth), nil
+}
+
+// GoRpcOpts converts the provided Java RpcOptions into Go call options.
+//
+// Of the security levels, only the absence of security can be requested (via
+// the boolean securityNone field), and of the discharge controls, only the
+// suppression of discharge fetching (via the boolean noDischarges field).
 func GoRpcOpts(env jutil.Env, obj jutil.Object) ([]rpc.CallOpt, error) {
 	var opts []rpc.CallOpt
 
@@ -84,9 +266,17 @@ func GoRpcOpts(env jutil.Env, obj jutil.Object) ([]rpc.CallOpt, error) {
 		opts = append(opts, options.NameResolutionAuthorizer{opt})
 	}
 
-	if opt, err := getAuthorizer(env, obj, "serverAuthorizer"); err != nil {
+	// Servers must satisfy both the server authorizer and the allowed server
+	// patterns, if both are set.
+	auth, err := getAuthorizer(env, obj, "serverAuthorizer")
+	if err != nil {
 		return nil, err
-	} else if opt != nil {
+	}
+	allowed, err := getBlessingPatterns(env, obj, "allowedServers")
+	if err != nil {
+		return nil, err
+	}
+	if opt := serverAuthorizer(auth, allowed); opt != nil {
 		opts = append(opts, options.ServerAuthorizer{opt})
 	}
 
@@ -114,35 +304,149 @@ func GoRpcOpts(env jutil.Env, obj jutil.Object) ([]rpc.CallOpt, error) {
 		opts = append(opts, options.ChannelTimeout(*opt))
 	}
 
-	return opts, nil
-}
-
-func GoRpcServerOpts(env jutil.Env, obj jutil.Object) ([]rpc.ServerOpt, error) {
-	var opts []rpc.ServerOpt
-
-	if opt, err := jutil.JBoolField(env, obj, "servesMountTable"); err != nil {
+	if opt, err := getGranter(env, obj); err != nil {
 		return nil, err
-	} else {
-		opts = append(opts, options.ServesMountTable(opt))
+	} else if opt != nil {
+		opts = append(opts, opt)
 	}
 
-	if opt, err := getDuration(env, obj, "lameDuckTimeout"); err != nil {
+	if opt, err := getPublicKey(env, obj, "serverPublicKey"); err != nil {
 		return nil, err
 	} else if opt != nil {
-		opts = append(opts, options.LameDuckTimeout(*opt))
+		opts = append(opts, options.ServerPublicKey{opt})
 	}
 
-	if opt, err := jutil.JBoolField(env, obj, "isLeaf"); err != nil {
+	if opt, err := jutil.JBoolField(env, obj, "securityNone"); err != nil {
 		return nil, err
-	} else {
-		opts = append(opts, options.IsLeaf(opt))
+	} else if opt {
+		opts = append(opts, options.SecurityNone)
 	}
 
-	if opt, err := getDuration(env, obj, "channelTimeout"); err != nil {
+	if opt, err := getRetryPolicy(env, obj); err != nil {
 		return nil, err
 	} else if opt != nil {
-		opts = append(opts, options.ChannelTimeout(*opt))
+		opts = append(opts, *opt)
+	}
+
+	if opt, err := jutil.JBoolField(env, obj, "noDischarges"); err != nil {
+		return nil, err
+	} else if opt {
+		opts = append(opts, options.NoDischarges{})
 	}
 
 	return opts, nil
 }
+
+// GoRpcServerOpts converts the provided Java RpcServerOptions into Go server
+// options.
+func GoRpcServerOpts(env jutil.Env, obj jutil.Object) ([]rpc.ServerOpt, error) {
+	var o serverOptions
+	var err error
+	if o.servesMountTable, err = jutil.JBoolField(env, obj, "servesMountTable"); err != nil {
+		return nil, err
+	}
+	if o.lameDuckTimeout, err = getDuration(env, obj, "lameDuckTimeout"); err != nil {
+		return nil, err
+	}
+	if o.isLeaf, err = jutil.JBoolField(env, obj, "isLeaf"); err != nil {
+		return nil, err
+	}
+	if o.channelTimeout, err = getDuration(env, obj, "channelTimeout"); err != nil {
+		return nil, err
+	}
+	if o.serverBlessings, err = getBlessings(env, obj, "serverBlessings"); err != nil {
+		return nil, err
+	}
+	if o.serverPeers, err = getBlessingPatterns(env, obj, "serverPeers"); err != nil {
+		return nil, err
+	}
+	if o.reservedNameDispatcher, err = getReservedNameDispatcher(env, obj); err != nil {
+		return nil, err
+	}
+	return o.serverOpts(), nil
+}
+
+// GoRpcServerPublishName returns the name under which a server created with
+// the provided name and Java RpcServerOptions should publish itself in the
+// namespace: none if the options request that the server not publish itself,
+// regardless of the name it was created with.  Names can still be published
+// later on via Server.addName().
+func GoRpcServerPublishName(env jutil.Env, obj jutil.Object, name string) (string, error) {
+	noPublish, err := jutil.JBoolField(env, obj, "noPublish")
+	if err != nil {
+		return "", err
+	}
+	return publishName(name, noPublish), nil
+}
+
+// GoRpcServerHealthAuthorizer returns the authorizer of the health service
+// requested by the provided Java RpcServerOptions, or nil if the options
+// don't provide one.
+func GoRpcServerHealthAuthorizer(env jutil.Env, obj jutil.Object) (security.Authorizer, error) {
+	return getAuthorizer(env, obj, "healthAuthorizer")
+}
+
+// GoRpcServerServiceMounts returns the Go services that the provided Java
+// RpcServerOptions request to be mounted alongside the server's Java
+// dispatcher, as a map from suffix prefixes to registered service names.
+func GoRpcServerServiceMounts(env jutil.Env, obj jutil.Object) (map[string]string, error) {
+	jMounts, err := jutil.JObjectField(env, obj, "goServiceMounts", jutil.MapSign)
+	if err != nil {
+		return nil, err
+	}
+	if jMounts.IsNull() {
+		return nil, nil
+	}
+	m, err := jutil.GoObjectMap(env, jMounts)
+	if err != nil {
+		return nil, err
+	}
+	mounts := make(map[string]string)
+	for jPrefix, jName := range m {
+		mounts[jutil.GoString(env, jPrefix)] = jutil.GoString(env, jName)
+	}
+	return mounts, nil
+}
+
+// GoRpcServerInterceptorOpts returns the interceptor settings of the provided
+// Java RpcServerOptions.
+func GoRpcServerInterceptorOpts(env jutil.Env, obj jutil.Object) (ServerInterceptorOpts, error) {
+	var o ServerInterceptorOpts
+	var err error
+	if o.RecoverPanics, err = jutil.JBoolField(env, obj, "recoverPanics"); err != nil {
+		return o, err
+	}
+	if o.RateLimit, err = jutil.JDoubleField(env, obj, "rateLimit"); err != nil {
+		return o, err
+	}
+	if o.RateLimitBurst, err = jutil.JIntField(env, obj, "rateLimitBurst"); err != nil {
+		return o, err
+	}
+	if o.LogCalls, err = jutil.JBoolField(env, obj, "logCalls"); err != nil {
+		return o, err
+	}
+	if o.MetricsPrefix, err = jutil.JStringField(env, obj, "metricsPrefix"); err != nil {
+		return o, err
+	}
+	if o.AuditAuthorization, err = jutil.JBoolField(env, obj, "auditAuthorization"); err != nil {
+		return o, err
+	}
+	if o.MaxConcurrentCalls, err = jutil.JIntField(env, obj, "maxConcurrentCalls"); err != nil {
+		return o, err
+	}
+	if o.MaxConcurrentCallsPerMethod, err = jutil.JIntField(env, obj, "maxConcurrentCallsPerMethod"); err != nil {
+		return o, err
+	}
+	if o.MethodConcurrencyLimits, err = getMethodLimits(env, obj, "methodConcurrencyLimits"); err != nil {
+		return o, err
+	}
+	if o.MaxQueuedCalls, err = jutil.JIntField(env, obj, "maxQueuedCalls"); err != nil {
+		return o, err
+	}
+	if wait, err := getDuration(env, obj, "maxQueueWait"); err != nil {
+		return o, err
+	} else if wait != nil {
+		o.MaxQueueWait = *wait
+	}
+	return o, nil
+}
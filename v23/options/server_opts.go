@@ -0,0 +1,72 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"time"
+
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+)
+
+// serverOptions holds the values of the fields of Java RpcServerOptions that
+// map to Go server options.  Nil values are unset.
+type serverOptions struct {
+	servesMountTable       bool
+	lameDuckTimeout        *time.Duration
+	isLeaf                 bool
+	channelTimeout         *time.Duration
+	serverBlessings        *security.Blessings
+	serverPeers            []security.BlessingPattern
+	reservedNameDispatcher rpc.Dispatcher
+}
+
+// serverOpts returns the Go server options corresponding to o.
+func (o *serverOptions) serverOpts() []rpc.ServerOpt {
+	opts := []rpc.ServerOpt{options.ServesMountTable(o.servesMountTable)}
+	if o.lameDuckTimeout != nil {
+		opts = append(opts, options.LameDuckTimeout(*o.lameDuckTimeout))
+	}
+	opts = append(opts, options.IsLeaf(o.isLeaf))
+	if o.channelTimeout != nil {
+		opts = append(opts, options.ChannelTimeout(*o.channelTimeout))
+	}
+	if o.serverBlessings != nil {
+		opts = append(opts, options.ServerBlessings{Blessings: *o.serverBlessings})
+	}
+	if o.serverPeers != nil {
+		opts = append(opts, options.ServerPeers(o.serverPeers))
+	}
+	if o.reservedNameDispatcher != nil {
+		opts = append(opts, options.ReservedNameDispatcher{Dispatcher: o.reservedNameDispatcher})
+	}
+	return opts
+}
+
+// publishName returns the name under which a server created with the provided
+// name publishes itself: none if noPublish is set.
+func publishName(name string, noPublish bool) string {
+	if noPublish {
+		return ""
+	}
+	return name
+}
+
+// ServerInterceptorOpts holds the values of the fields of Java RpcServerOptions
+// that configure the interceptor chain of a server.  Zero values are unset.
+type ServerInterceptorOpts struct {
+	RecoverPanics               bool
+	RateLimit                   float64
+	RateLimitBurst              int
+	LogCalls                    bool
+	MetricsPrefix               string
+	AuditAuthorization          bool
+	MaxConcurrentCalls          int
+	MaxConcurrentCallsPerMethod int
+	MethodConcurrencyLimits     map[string]int
+	MaxQueuedCalls              int
+	MaxQueueWait                time.Duration
+}
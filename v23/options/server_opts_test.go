@@ -0,0 +1,68 @@
+// Copyright 2016 The Vanadium Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package options
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"v.io/v23/options"
+	"v.io/v23/rpc"
+	"v.io/v23/security"
+)
+
+// TestServerOpts checks that the values read from Java map to the
+// corresponding Go server options.  Whether each option takes effect is up to
+// the runtime's server, which isn't exercised here.
+func TestServerOpts(t *testing.T) {
+	timeout := 5 * time.Second
+	var blessings security.Blessings
+	peers := []security.BlessingPattern{"root:alice", "root:bob"}
+	debug := reservedNameDispatcher(true, nil)
+	tests := []struct {
+		opts serverOptions
+		want []rpc.ServerOpt
+	}{
+		{
+			serverOptions{},
+			[]rpc.ServerOpt{options.ServesMountTable(false), options.IsLeaf(false)},
+		},
+		{
+			serverOptions{
+				servesMountTable:       true,
+				lameDuckTimeout:        &timeout,
+				isLeaf:                 true,
+				channelTimeout:         &timeout,
+				serverBlessings:        &blessings,
+				serverPeers:            peers,
+				reservedNameDispatcher: debug,
+			},
+			[]rpc.ServerOpt{
+				options.ServesMountTable(true),
+				options.LameDuckTimeout(timeout),
+				options.IsLeaf(true),
+				options.ChannelTimeout(timeout),
+				options.ServerBlessings{Blessings: blessings},
+				options.ServerPeers(peers),
+				options.ReservedNameDispatcher{Dispatcher: debug},
+			},
+		},
+	}
+	for _, test := range tests {
+		if got := test.opts.serverOpts(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got server options %#v, want %#v", got, test.want)
+		}
+	}
+}
+
+func TestPublishName(t *testing.T) {
+	if got := publishName("server", false); got != "server" {
+		t.Errorf("got publish name %q, want %q", got, "server")
+	}
+	if got := publishName("server", true); got != "" {
+		t.Errorf("got publish name %q with no-publish set, want none", got)
+	}
+}